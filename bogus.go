@@ -4,12 +4,22 @@ package bogus
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
 	"io/ioutil"
+	"math/rand"
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/http/httputil"
 	"net/url"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
 
+	"github.com/gomicro/bogus/internal/timing"
 	"github.com/gomicro/bogus/paths"
 )
 
@@ -21,80 +31,560 @@ type HitRecord struct {
 	Query  url.Values
 	Body   []byte
 	Header http.Header
+	Params map[string]string
+
+	// Proto is the protocol the request was made with, such as HTTP/1.1 or
+	// HTTP/2.0
+	Proto string
+
+	// PeerCertSubject is the subject of the certificate presented by the
+	// client when serving over mutual TLS
+	PeerCertSubject string
+
+	// Status, ResponseHeader, and ResponseBody are what the server replied
+	// with
+	Status         int
+	ResponseHeader http.Header
+	ResponseBody   []byte
+
+	// RawBody and RawResponseBody are the request and response bodies as
+	// they were sent when compressed, with Body and ResponseBody holding
+	// them uncompressed. They are only set when WithCompression is used and
+	// the body was compressed.
+	RawBody         []byte
+	RawResponseBody []byte
+
+	// ShortCircuit is whether a conditional request was answered with 304
+	// Not Modified or 412 Precondition Failed instead of the full response
+	ShortCircuit bool
+
+	// Canceled is whether the client went away before the response was
+	// complete, such as by timing out during a delay
+	Canceled bool
+
+	// Fault is the fault injected into the response, if any. The status,
+	// headers, and body are left empty when the fault took over the
+	// connection.
+	Fault paths.Fault
+
+	// Frames are the websocket frames sent and received after the request
+	// was upgraded by a path added with AddWebSocket, in order
+	Frames []WebSocketFrame
+
+	// Proxied is whether the request was forwarded to the upstream set with
+	// WithProxy
+	Proxied bool
+
+	// Route is the pattern of the path which handled the request, and
+	// Expectation the expectation on it which answered, if any
+	Route       string
+	Expectation *paths.Expectation
+
+	// Time is when the request arrived and Duration how long it took to
+	// respond to
+	Time     time.Time
+	Duration time.Duration
+
+	// RemoteAddr, Host, and RequestURI are as they were received by the
+	// server
+	RemoteAddr string
+	Host       string
+	RequestURI string
+
+	// seq numbers records in the order they arrived, and is never reused
+	seq int64
 }
 
-// Bogus represents a test server
+// Bogus represents a test server. It is safe for concurrent use; paths may be
+// added while the server is handling requests.
 type Bogus struct {
-	server     *httptest.Server
-	hits       int
-	paths      map[string]*paths.Path
-	hitRecords []HitRecord
+	server             *httptest.Server
+	clientCertificates []tls.Certificate
+	proxy              *httputil.ReverseProxy
+	latency            paths.Latency
+	fault              paths.Fault
+	faultProbability   float64
+	compression        bool
+	done               chan struct{}
+	closeOnce          sync.Once
+	tls                bool
+	h2c                bool
+	hits               int64
+
+	mu               sync.RWMutex
+	paths            map[string]*paths.Path
+	routes           []*route
+	rng              *rand.Rand
+	hitRecords       []HitRecord
+	nextSeq          int64
+	failOnUnexpected bool
+	logf             func(format string, args ...interface{})
 }
 
-// New returns a newly intitated bogus server
-func New() *Bogus {
+// New returns a newly intitated bogus server configured by any options
+// provided. Unless WithUnstarted is provided the server is started before it
+// is returned. It panics if the options cannot be applied.
+func New(opts ...Option) *Bogus {
+	cfg := &config{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
 	b := &Bogus{
-		paths: map[string]*paths.Path{},
+		paths:              map[string]*paths.Path{},
+		clientCertificates: cfg.clientCertificates,
+		proxy:              cfg.proxy,
+		latency:            cfg.latency,
+		fault:              cfg.fault,
+		faultProbability:   cfg.faultProbability,
+		compression:        cfg.compression,
+		done:               make(chan struct{}),
+		rng:                rand.New(rand.NewSource(time.Now().UnixNano())),
+		tls:                cfg.tls,
+		h2c:                cfg.h2c && !cfg.tls,
+	}
+	if cfg.seed != nil {
+		b.rng = rand.New(rand.NewSource(*cfg.seed))
+	}
+
+	b.server = httptest.NewUnstartedServer(http.HandlerFunc(b.HandlePaths))
+	b.server.EnableHTTP2 = cfg.http2
+	b.server.Config.ReadTimeout = cfg.readTimeout
+	b.server.Config.WriteTimeout = cfg.writeTimeout
+	b.server.Config.IdleTimeout = cfg.idleTimeout
+
+	listener := cfg.listener
+	if listener == nil && cfg.address != "" {
+		l, err := net.Listen("tcp", cfg.address)
+		if err != nil {
+			b.server.Close()
+			panic("bogus: listening on " + cfg.address + ": " + err.Error())
+		}
+
+		listener = l
+	}
+
+	if listener != nil {
+		b.server.Listener.Close()
+		b.server.Listener = listener
+	}
+
+	if b.h2c {
+		if err := enableH2C(b.server); err != nil {
+			b.server.Close()
+			panic("bogus: serving h2c: " + err.Error())
+		}
+	}
+
+	if cfg.tls {
+		tlsCfg, err := tlsConfig(cfg)
+		if err != nil {
+			b.server.Close()
+			panic("bogus: configuring tls: " + err.Error())
+		}
+
+		b.server.TLS = tlsCfg
+	}
+
+	if !cfg.unstarted {
+		b.Start()
+	}
+
+	return b
+}
+
+// NewT returns a newly initiated bogus server tied to the test provided and
+// configured by any options provided. The server is closed when the test and
+// its subtests complete, at which point any unmet expectations and any
+// requests not routed to a path are reported as errors on the test. When tests
+// are run verbosely each request is logged to the test as well.
+func NewT(t testing.TB, opts ...Option) *Bogus {
+	b := New(opts...)
+	b.SetFailOnUnexpected(true)
+
+	if testing.Verbose() {
+		b.SetLogf(t.Logf)
 	}
-	b.server = httptest.NewServer(http.HandlerFunc(b.HandlePaths))
+
+	t.Cleanup(func() {
+		b.Close()
+		b.Verify(t)
+	})
 
 	return b
 }
 
+// Start starts a server created with WithUnstarted. It panics if the server
+// has already been started.
+func (b *Bogus) Start() {
+	if b.tls {
+		b.server.StartTLS()
+		return
+	}
+
+	b.server.Start()
+}
+
+// SetLogf sets a function to log each request handled by the server, such as
+// the Logf method of a test. A nil function disables logging.
+func (b *Bogus) SetLogf(logf func(format string, args ...interface{})) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.logf = logf
+}
+
 // AddPath adds a new path to the bogus server handler and returns the new path
-// for further configuration
+// for further configuration. The path may be a pattern containing parameters
+// such as /users/{id} or /users/{id:[0-9]+}, or ending with a wildcard such as
+// /files/*rest, which matches anything below /files/ but not /files itself.
+// When several patterns match a request, static segments are preferred over
+// parameters and parameters over wildcards. AddPath panics if the pattern is
+// invalid.
 func (b *Bogus) AddPath(path string) *paths.Path {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if p, ok := b.paths[path]; ok {
+		return p
+	}
+
+	rt, err := parseRoute(path)
+	if err != nil {
+		panic("bogus: invalid path " + path + ": " + err.Error())
+	}
+
+	rt.path = paths.New().
+		SetSeed(b.rng.Int63())
+	b.paths[path] = rt.path
+	b.routes = append(b.routes, rt)
+	sort.SliceStable(b.routes, func(i, j int) bool {
+		return b.routes[i].before(b.routes[j])
+	})
+
+	return rt.path
+}
+
+// RemovePath removes the path added with the pattern provided, so requests to
+// it are no longer routed to it. It reports whether the path was found.
+func (b *Bogus) RemovePath(path string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
 	if _, ok := b.paths[path]; !ok {
-		b.paths[path] = paths.New()
+		return false
+	}
+
+	delete(b.paths, path)
+	for i, rt := range b.routes {
+		if rt.pattern == path {
+			b.routes = append(b.routes[:i], b.routes[i+1:]...)
+			break
+		}
 	}
 
-	return b.paths[path]
+	return true
 }
 
-// Close calls the close method for the underlying httptest server
+// ResetHits clears the server's hit count and hit records, and the hit counts
+// and response queues of each of its paths, leaving the paths themselves in
+// place
+func (b *Bogus) ResetHits() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	atomic.StoreInt64(&b.hits, 0)
+	b.hitRecords = nil
+
+	for _, p := range b.paths {
+		p.ResetHits()
+	}
+}
+
+// Reset removes every path from the server and clears its hit count and hit
+// records, returning it to the state it was created in
+func (b *Bogus) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	atomic.StoreInt64(&b.hits, 0)
+	b.hitRecords = nil
+	b.paths = map[string]*paths.Path{}
+	b.routes = nil
+}
+
+// Close calls the close method for the underlying httptest server, first
+// releasing any requests held open by delays or stalls
 func (b *Bogus) Close() {
+	b.closeOnce.Do(func() {
+		close(b.done)
+	})
+
 	b.server.Close()
 }
 
 // HandlePaths implements the http handler interface and decides how to respond
 // based on the paths configured
 func (b *Bogus) HandlePaths(w http.ResponseWriter, r *http.Request) {
-	b.hits++
+	atomic.AddInt64(&b.hits, 1)
+	start := time.Now()
 
 	bodyBytes, _ := ioutil.ReadAll(r.Body)
+	header := r.Header.Clone()
+
+	var rawBody []byte
+	if b.compression {
+		if decoded, ok := decodeBody(r.Header.Get("Content-Encoding"), bodyBytes); ok {
+			rawBody, bodyBytes = bodyBytes, decoded
+			r.Header.Del("Content-Encoding")
+			r.ContentLength = int64(len(decoded))
+		}
+	}
+
 	r.Body = ioutil.NopCloser(bytes.NewBuffer(bodyBytes))
 	defer r.Body.Close()
 
-	b.hitRecords = append(b.hitRecords, HitRecord{
+	b.mu.Lock()
+	rt, params := b.route(r.URL.Path)
+	record := HitRecord{
 		Verb:   r.Method,
 		Path:   r.URL.Path,
 		Query:  r.URL.Query(),
 		Body:   bodyBytes,
-		Header: r.Header,
+		Header: header,
+		Params: params,
+		Proto:  r.Proto,
+
+		RawBody: rawBody,
+
+		Time:       start,
+		RemoteAddr: r.RemoteAddr,
+		Host:       r.Host,
+		RequestURI: r.RequestURI,
+	}
+	if r.TLS != nil && len(r.TLS.PeerCertificates) != 0 {
+		record.PeerCertSubject = r.TLS.PeerCertificates[0].Subject.String()
+	}
+	if rt != nil {
+		record.Route = rt.pattern
+	}
+	record.Proxied = rt == nil && b.proxy != nil
+	record.seq = b.nextSeq
+	b.nextSeq++
+	b.hitRecords = append(b.hitRecords, record)
+	logf := b.logf
+	var delay time.Duration
+	if b.latency != nil {
+		delay = b.latency.Duration(b.rng)
+	}
+	fault := paths.NoFault
+	if b.fault != paths.NoFault && b.rng.Float64() < b.faultProbability {
+		fault = b.fault
+	}
+	b.mu.Unlock()
+
+	if logf != nil {
+		logf("bogus: %v", record.requestLine())
+	}
+
+	orig := r
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	go func() {
+		select {
+		case <-b.done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	r = r.WithContext(context.WithValue(ctx, recordKey{}, record.seq))
+
+	var cw *compressWriter
+	if b.compression {
+		cw = newCompressWriter(w, r)
+		w = cw
+	}
+
+	rec := newResponseRecorder(w)
+	match := &paths.Match{}
+	defer b.finish(record.seq, orig, rec, cw, match)
+	if cw != nil {
+		defer cw.close()
+	}
+
+	if !timing.Wait(r, delay) {
+		return
+	}
+
+	if fault != paths.NoFault {
+		match.Fault = fault
+		fault.Inject(rec, r)
+		return
+	}
+
+	switch {
+	case record.Proxied:
+		b.proxy.ServeHTTP(rec, r)
+
+	case rt == nil:
+		rec.WriteHeader(http.StatusNotFound)
+		rec.Write([]byte("Not Found")) //nolint,errcheck
+
+	default:
+		ctx := paths.WithMatch(paths.WithParams(r.Context(), params), match)
+		rt.path.HandleRequest(rec, r.WithContext(ctx))
+	}
+}
+
+// finish completes the hit record numbered by the sequence provided with the
+// response that was written and how it was matched. Responses written over a
+// hijacked connection are left for whatever hijacked it to record, and the
+// response is left empty when the client went away or a fault fired before
+// anything was written.
+func (b *Bogus) finish(seq int64, r *http.Request, rec *responseRecorder, cw *compressWriter, match *paths.Match) {
+	canceled := r.Context().Err() != nil
+
+	// the server only answers 200 by default when nothing was written if the
+	// client is still there to receive it and no fault cut the response off
+	implicit := !rec.hijacked && !canceled && match.Fault == paths.NoFault
+
+	b.update(seq, func(h *HitRecord) {
+		if rec.wroteHeader || implicit {
+			status, header := rec.status, rec.header
+			if !rec.wroteHeader {
+				status, header = http.StatusOK, rec.Header().Clone()
+			}
+
+			h.Status = status
+			h.ResponseHeader = header
+			h.ResponseBody = rec.body.Bytes()
+
+			if cw != nil && cw.enc != nil {
+				h.RawResponseBody = cw.raw.Bytes()
+			}
+		}
+
+		h.Expectation = match.Expectation
+		h.Fault = match.Fault
+		h.ShortCircuit = match.ShortCircuit
+		h.Duration = time.Since(h.Time)
+		h.Canceled = canceled
 	})
+}
 
-	path, ok := b.paths[r.URL.Path]
-	if !ok {
-		w.WriteHeader(http.StatusNotFound)
-		w.Write([]byte("Not Found")) //nolint,errcheck
+// update applies the function to the hit record numbered by the sequence
+// provided. Records cleared by a reset while the request was being handled
+// are left alone.
+func (b *Bogus) update(seq int64, fn func(*HitRecord)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.hitRecords) == 0 {
 		return
 	}
 
-	path.HandleRequest(w, r)
+	idx := int(seq - b.hitRecords[0].seq)
+	if idx < 0 || idx >= len(b.hitRecords) {
+		return
+	}
+
+	fn(&b.hitRecords[idx])
+}
+
+// route finds the highest precedence route matching the request path along
+// with any parameters it captured. The caller must hold the lock.
+func (b *Bogus) route(urlPath string) (*route, map[string]string) {
+	for _, rt := range b.routes {
+		if params, ok := rt.match(urlPath); ok {
+			return rt, params
+		}
+	}
+
+	return nil, nil
 }
 
 // Hits returns the total number of hits seen against the bogus server
 func (b *Bogus) Hits() int {
-	return b.hits
+	return int(atomic.LoadInt64(&b.hits))
 }
 
-// HitRecords returns a slice of the hit records recorded for inspection
+// HitRecords returns a snapshot of the hit records recorded for inspection.
+// The returned slice is a copy and will not change as further hits arrive.
 func (b *Bogus) HitRecords() []HitRecord {
-	return b.hitRecords
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	records := make([]HitRecord, len(b.hitRecords))
+	copy(records, b.hitRecords)
+
+	return records
 }
 
 // HostPort returns the host and port number of the bogus server
 func (b *Bogus) HostPort() (string, string) {
-	h, p, _ := net.SplitHostPort(b.server.URL[7:])
-	return h, p
+	u, err := url.Parse(b.URL())
+	if err != nil {
+		return "", ""
+	}
+
+	return u.Hostname(), u.Port()
+}
+
+// URL returns the base URL of the bogus server, using https when serving over
+// TLS. When listening on a unix socket the URL's host is localhost, and only
+// the client returned by Client will reach the server through it. It returns
+// an empty string if the server has not been started.
+func (b *Bogus) URL() string {
+	if b.server.URL == "" || !b.unix() {
+		return b.server.URL
+	}
+
+	if b.tls {
+		return "https://localhost"
+	}
+
+	return "http://localhost"
+}
+
+// Client returns an http client configured to talk to the bogus server. When
+// serving over TLS it trusts the server's certificate and presents any client
+// certificate provided with WithClientCertificate. When serving h2c it speaks
+// cleartext HTTP/2, and when listening on a unix socket it dials the socket.
+func (b *Bogus) Client() *http.Client {
+	client := b.server.Client()
+
+	tr, ok := client.Transport.(*http.Transport)
+	if !ok {
+		return client
+	}
+
+	needsClientCerts := tr.TLSClientConfig != nil && len(b.clientCertificates) != 0
+	if !b.h2c && !needsClientCerts && !b.unix() {
+		return client
+	}
+
+	tr = tr.Clone()
+
+	if b.h2c {
+		h2cTransport(tr)
+	}
+
+	if needsClientCerts {
+		tr.TLSClientConfig = tr.TLSClientConfig.Clone()
+		tr.TLSClientConfig.Certificates = b.clientCertificates
+	}
+
+	if b.unix() {
+		socket := b.server.Listener.Addr().String()
+		tr.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		}
+	}
+
+	return &http.Client{Transport: tr}
+}
+
+func (b *Bogus) unix() bool {
+	return b.server.Listener != nil && b.server.Listener.Addr().Network() == "unix"
 }
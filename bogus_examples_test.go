@@ -23,7 +23,7 @@ func ExampleBogus() {
 		SetStatus(http.StatusOK)
 	host, port := server.HostPort()
 
-	resp, err := http.Get(fmt.Sprintf("https://%v:%v", host, port))
+	resp, err := http.Get(fmt.Sprintf("http://%v:%v", host, port))
 	if err != nil {
 		t.Errorf("expected nil error, got %v", err.Error())
 	}
@@ -67,7 +67,7 @@ func ExampleBogus_goblinGomega() {
 
 			host, port := server.HostPort()
 
-			_, err := http.Get(fmt.Sprintf("https://%v:%v", host, port))
+			_, err := http.Get(fmt.Sprintf("http://%v:%v", host, port))
 			Expect(err).To(BeNil())
 			Expect(server.Hits()).To(Equal(1))
 		})
@@ -87,7 +87,7 @@ func ExampleBogus_goblinGomega() {
 				SetStatus(http.StatusOK)
 			host, port := server.HostPort()
 
-			resp, err := http.Get(fmt.Sprintf("https://%v:%v", host, port))
+			resp, err := http.Get(fmt.Sprintf("http://%v:%v", host, port))
 			Expect(err).To(BeNil())
 			defer resp.Body.Close()
 
@@ -100,3 +100,25 @@ func ExampleBogus_goblinGomega() {
 		})
 	})
 }
+
+func ExampleWithTLS() {
+	// This would normally be provided by a normal testing function setup
+	var t *testing.T
+
+	server := bogus.New(bogus.WithTLS())
+	defer server.Close()
+
+	server.AddPath("/foo/bar").
+		SetMethods("GET").
+		SetPayload([]byte("some return payload"))
+
+	resp, err := server.Client().Get(server.URL() + "/foo/bar")
+	if err != nil {
+		t.Errorf("expected nil error, got %v", err.Error())
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected status 200, got %v", resp.StatusCode)
+	}
+}
@@ -6,9 +6,13 @@ import (
 	"net"
 	"net/http"
 	"net/url"
+	"strconv"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/franela/goblin"
+	"github.com/gomicro/bogus/paths"
 	. "github.com/onsi/gomega"
 )
 
@@ -186,5 +190,249 @@ func TestBogus(t *testing.T) {
 
 			Expect(resp.Header.Get("Content-Type")).To(Equal("application/json"))
 		})
+
+		g.It("should be safe for concurrent use", func() {
+			server.AddPath("/").
+				SetMethods("GET")
+
+			workers := 8
+			perWorker := 25
+
+			var wg sync.WaitGroup
+			for i := 0; i < workers; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+
+					server.AddPath("/worker/" + strconv.Itoa(i)).
+						SetMethods("GET").
+						SetStatus(http.StatusAccepted)
+
+					for j := 0; j < perWorker; j++ {
+						resp, err := http.Get("http://" + net.JoinHostPort(host, port))
+						if err == nil {
+							resp.Body.Close()
+						}
+
+						server.HitRecords()
+						server.Hits()
+					}
+				}(i)
+			}
+			wg.Wait()
+
+			Expect(server.Hits()).To(Equal(workers * perWorker))
+			Expect(server.HitRecords()).To(HaveLen(workers * perWorker))
+			Expect(server.AddPath("/").Hits()).To(Equal(workers * perWorker))
+		})
+
+		g.It("should return a snapshot of hit records", func() {
+			resp, err := http.Get("http://" + net.JoinHostPort(host, port))
+			Expect(err).NotTo(HaveOccurred())
+			resp.Body.Close()
+
+			records := server.HitRecords()
+			Expect(records).To(HaveLen(1))
+
+			resp, err = http.Get("http://" + net.JoinHostPort(host, port))
+			Expect(err).NotTo(HaveOccurred())
+			resp.Body.Close()
+
+			Expect(records).To(HaveLen(1))
+			Expect(server.HitRecords()).To(HaveLen(2))
+		})
+
+		g.It("should route templated paths and record params", func() {
+			server.AddPath("/users/{id}").
+				SetMethods("GET").
+				SetPayload([]byte("user"))
+
+			server.AddPath("/users/me").
+				SetMethods("GET").
+				SetPayload([]byte("me"))
+
+			server.AddPath("/files/*rest").
+				SetMethods("GET").
+				SetPayload([]byte("file"))
+
+			resp, err := http.Get("http://" + net.JoinHostPort(host, port) + "/users/42")
+			Expect(err).NotTo(HaveOccurred())
+			body, _ := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			Expect(string(body)).To(Equal("user"))
+
+			resp, err = http.Get("http://" + net.JoinHostPort(host, port) + "/users/me")
+			Expect(err).NotTo(HaveOccurred())
+			body, _ = ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			Expect(string(body)).To(Equal("me"))
+
+			resp, err = http.Get("http://" + net.JoinHostPort(host, port) + "/files/a/b.txt")
+			Expect(err).NotTo(HaveOccurred())
+			body, _ = ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			Expect(string(body)).To(Equal("file"))
+
+			records := server.HitRecords()
+			Expect(records).To(HaveLen(3))
+			Expect(records[0].Params).To(Equal(map[string]string{"id": "42"}))
+			Expect(records[1].Params).To(BeEmpty())
+			Expect(records[2].Params).To(Equal(map[string]string{"rest": "a/b.txt"}))
+		})
+
+		g.It("should record the full response and how it was matched", func() {
+			p := server.AddPath("/orders/{id}")
+			p.SetMethods("POST").
+				SetDelay(5 * time.Millisecond)
+			rush := p.Expect(paths.HeaderEquals("X-Rush", "true")).
+				Respond(paths.Response{
+					Status:  http.StatusCreated,
+					Headers: map[string]string{"X-Order": "rushed"},
+					Payload: []byte("rushed"),
+				})
+
+			before := time.Now()
+			req, _ := http.NewRequest("POST", "http://"+net.JoinHostPort(host, port)+"/orders/7?a=%2Fb", nil)
+			req.Header.Set("X-Rush", "true")
+			req.Host = "orders.example.com"
+			resp, err := http.DefaultClient.Do(req)
+			Expect(err).NotTo(HaveOccurred())
+			resp.Body.Close()
+
+			resp, err = http.Get("http://" + net.JoinHostPort(host, port) + "/orders/7")
+			Expect(err).NotTo(HaveOccurred())
+			resp.Body.Close()
+
+			records := server.HitRecords()
+			Expect(records).To(HaveLen(2))
+
+			rec := records[0]
+			Expect(rec.Status).To(Equal(http.StatusCreated))
+			Expect(rec.ResponseHeader.Get("X-Order")).To(Equal("rushed"))
+			Expect(string(rec.ResponseBody)).To(Equal("rushed"))
+			Expect(rec.Route).To(Equal("/orders/{id}"))
+			Expect(rec.Expectation).To(BeIdenticalTo(rush))
+			Expect(rec.Time).To(BeTemporally(">=", before))
+			Expect(rec.Duration).To(BeNumerically(">=", 5*time.Millisecond))
+			Expect(rec.RemoteAddr).To(HavePrefix("127.0.0.1:"))
+			Expect(rec.Host).To(Equal("orders.example.com"))
+			Expect(rec.RequestURI).To(Equal("/orders/7?a=%2Fb"))
+			Expect(rec.String()).To(ContainSubstring("=> 201 Created\nX-Order: rushed"))
+
+			Expect(records[1].Status).To(Equal(http.StatusForbidden))
+			Expect(records[1].Route).To(Equal("/orders/{id}"))
+			Expect(records[1].Expectation).To(BeNil())
+		})
+
+		g.It("should reset hits and paths", func() {
+			base := "http://" + net.JoinHostPort(host, port)
+			p := server.AddPath("/reset").
+				SetMethods("GET").
+				Times(1)
+
+			resp, err := http.Get(base + "/reset")
+			Expect(err).NotTo(HaveOccurred())
+			resp.Body.Close()
+			Expect(server.Hits()).To(Equal(1))
+
+			server.ResetHits()
+			Expect(server.Hits()).To(Equal(0))
+			Expect(server.HitRecords()).To(BeEmpty())
+			Expect(p.Hits()).To(Equal(0))
+
+			resp, err = http.Get(base + "/reset")
+			Expect(err).NotTo(HaveOccurred())
+			resp.Body.Close()
+			Expect(server.HitRecords()).To(HaveLen(1))
+			Expect(server.HitRecords()[0].Status).To(Equal(http.StatusOK))
+
+			Expect(server.RemovePath("/reset")).To(BeTrue())
+			Expect(server.RemovePath("/reset")).To(BeFalse())
+
+			resp, err = http.Get(base + "/reset")
+			Expect(err).NotTo(HaveOccurred())
+			resp.Body.Close()
+			Expect(resp.StatusCode).To(Equal(http.StatusNotFound))
+
+			server.AddPath("/other").SetMethods("GET")
+			server.Reset()
+			Expect(server.Hits()).To(Equal(0))
+			Expect(server.HitRecords()).To(BeEmpty())
+
+			resp, err = http.Get(base + "/other")
+			Expect(err).NotTo(HaveOccurred())
+			resp.Body.Close()
+			Expect(resp.StatusCode).To(Equal(http.StatusNotFound))
+		})
+
+		g.It("should scope hits to a checkpoint", func() {
+			base := "http://" + net.JoinHostPort(host, port)
+			server.AddPath("/shared").SetMethods("GET")
+
+			resp, err := http.Get(base + "/shared?case=1")
+			Expect(err).NotTo(HaveOccurred())
+			resp.Body.Close()
+
+			cp := server.Checkpoint()
+			Expect(cp.Hits()).To(Equal(0))
+
+			resp, err = http.Get(base + "/shared?case=2")
+			Expect(err).NotTo(HaveOccurred())
+			resp.Body.Close()
+
+			Expect(server.Hits()).To(Equal(2))
+			Expect(cp.Hits()).To(Equal(1))
+			Expect(cp.HitRecords()[0].Query.Get("case")).To(Equal("2"))
+			Expect(cp.Query().QueryParam("case", "1").Count()).To(Equal(0))
+
+			server.ResetHits()
+			resp, err = http.Get(base + "/shared?case=3")
+			Expect(err).NotTo(HaveOccurred())
+			resp.Body.Close()
+			Expect(cp.Hits()).To(Equal(1))
+			Expect(cp.HitRecords()[0].Query.Get("case")).To(Equal("3"))
+		})
+
+		g.It("should record conditional requests answered without the full response", func() {
+			server.AddPath("/cached").
+				SetMethods("GET").
+				SetPayload([]byte("cached")).
+				SetETag("v1")
+
+			req, _ := http.NewRequest("GET", "http://"+net.JoinHostPort(host, port)+"/cached", nil)
+			resp, err := http.DefaultClient.Do(req)
+			Expect(err).NotTo(HaveOccurred())
+			resp.Body.Close()
+
+			req.Header.Set("If-None-Match", resp.Header.Get("ETag"))
+			resp, err = http.DefaultClient.Do(req)
+			Expect(err).NotTo(HaveOccurred())
+			resp.Body.Close()
+			Expect(resp.StatusCode).To(Equal(http.StatusNotModified))
+
+			records := server.HitRecords()
+			Expect(records[0].ShortCircuit).To(BeFalse())
+			Expect(records[1].ShortCircuit).To(BeTrue())
+			Expect(records[1].Status).To(Equal(http.StatusNotModified))
+		})
+
+		g.It("should leave the response empty when the client gives up during a path delay", func() {
+			server.AddPath("/slow").
+				SetMethods("GET").
+				SetDelay(time.Second)
+
+			client := &http.Client{Timeout: 50 * time.Millisecond}
+			_, err := client.Get("http://" + net.JoinHostPort(host, port) + "/slow")
+			Expect(err).To(HaveOccurred())
+
+			Eventually(func() bool {
+				records := server.HitRecords()
+				return len(records) == 1 && records[0].Canceled
+			}).Should(BeTrue())
+
+			rec := server.HitRecords()[0]
+			Expect(rec.Status).To(Equal(0))
+			Expect(rec.ResponseHeader).To(BeNil())
+		})
 	})
 }
@@ -0,0 +1,36 @@
+package bogus
+
+// Checkpoint marks a point in a server's hit records, so the hits recorded
+// after it can be inspected apart from those before it. This lets one server
+// be shared across subtests without the hits of one bleeding into another.
+type Checkpoint struct {
+	b   *Bogus
+	seq int64
+}
+
+// Checkpoint returns a checkpoint of the hits recorded so far
+func (b *Bogus) Checkpoint() *Checkpoint {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return &Checkpoint{b: b, seq: b.nextSeq}
+}
+
+// Hits returns the number of hits recorded since the checkpoint was taken
+func (c *Checkpoint) Hits() int {
+	return c.Query().Count()
+}
+
+// HitRecords returns a snapshot of the hit records recorded since the
+// checkpoint was taken
+func (c *Checkpoint) HitRecords() []HitRecord {
+	return c.Query().All()
+}
+
+// Query returns a query over the hit records recorded since the checkpoint
+// was taken
+func (c *Checkpoint) Query() *HitQuery {
+	return c.b.Query().Where("recorded since checkpoint", func(h HitRecord) bool {
+		return h.seq >= c.seq
+	})
+}
@@ -0,0 +1,196 @@
+package bogus
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gomicro/bogus/internal/accept"
+)
+
+// decodeBody decodes a request body sent with the content coding provided,
+// reporting false if the coding is not one of gzip, deflate, or br or the body
+// does not decode.
+func decodeBody(coding string, body []byte) ([]byte, bool) {
+	var r io.Reader
+
+	switch strings.ToLower(strings.TrimSpace(coding)) {
+	case "gzip", "x-gzip":
+		gr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, false
+		}
+		r = gr
+
+	case "deflate":
+		// deflate is meant to be zlib wrapped, but some clients send it raw
+		zr, err := zlib.NewReader(bytes.NewReader(body))
+		if err != nil {
+			r = flate.NewReader(bytes.NewReader(body))
+		} else {
+			r = zr
+		}
+
+	case "br":
+		r = brotli.NewReader(bytes.NewReader(body))
+
+	default:
+		return nil, false
+	}
+
+	decoded, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, false
+	}
+
+	return decoded, true
+}
+
+// responseCoding picks the content coding to compress a response with from
+// those the request accepts, preferring gzip to deflate. It returns an empty
+// string if neither is accepted.
+func responseCoding(r *http.Request) string {
+	accepted := accept.Parse(r.Header.Values("Accept-Encoding"))
+	if accepted == nil {
+		return ""
+	}
+
+	best, bestQ := "", 0.0
+	for _, coding := range []string{"gzip", "deflate"} {
+		if q := accept.EncodingQuality(accepted, coding); q > bestQ {
+			best, bestQ = coding, q
+		}
+	}
+
+	return best
+}
+
+// compressWriter compresses a response with a coding the client accepts,
+// unless the response has a content coding of its own or no body, keeping a
+// copy of the compressed bytes
+type compressWriter struct {
+	http.ResponseWriter
+	r *http.Request
+
+	status  int
+	started bool
+	enc     io.WriteCloser
+	raw     bytes.Buffer
+}
+
+func newCompressWriter(w http.ResponseWriter, r *http.Request) *compressWriter {
+	return &compressWriter{ResponseWriter: w, r: r}
+}
+
+// WriteHeader decides on the content coding, but holds the status back until
+// the first write so the content type can still be sniffed from the
+// uncompressed body
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.status != 0 {
+		return
+	}
+
+	cw.status = status
+
+	h := cw.Header()
+	noBody := cw.r.Method == http.MethodHead || status < 200 ||
+		status == http.StatusNoContent || status == http.StatusNotModified
+	if noBody || h.Get("Content-Encoding") != "" {
+		return
+	}
+
+	coding := responseCoding(cw.r)
+	if coding == "" {
+		return
+	}
+
+	h.Set("Content-Encoding", coding)
+	h.Add("Vary", "Accept-Encoding")
+	h.Del("Content-Length")
+
+	out := io.MultiWriter(cw.ResponseWriter, &cw.raw)
+	if coding == "gzip" {
+		cw.enc = gzip.NewWriter(out)
+	} else {
+		cw.enc, _ = zlib.NewWriterLevel(out, zlib.DefaultCompression)
+	}
+}
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	cw.start(b)
+
+	if cw.enc != nil {
+		return cw.enc.Write(b)
+	}
+
+	return cw.ResponseWriter.Write(b)
+}
+
+// start writes the held back status, sniffing the content type from the
+// first bytes of the body when compressing
+func (cw *compressWriter) start(b []byte) {
+	if cw.status == 0 {
+		cw.WriteHeader(http.StatusOK)
+	}
+
+	if cw.started {
+		return
+	}
+	cw.started = true
+
+	if cw.enc != nil && cw.Header().Get("Content-Type") == "" && len(b) != 0 {
+		cw.Header().Set("Content-Type", http.DetectContentType(b))
+	}
+
+	cw.ResponseWriter.WriteHeader(cw.status)
+}
+
+// Flush implements http.Flusher, flushing any compressed data first
+func (cw *compressWriter) Flush() {
+	cw.start(nil)
+
+	if f, ok := cw.enc.(interface{ Flush() error }); ok {
+		f.Flush() //nolint,errcheck
+	}
+
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker when the underlying writer does
+func (cw *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := cw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("bogus: response writer does not support hijacking")
+	}
+
+	cw.started = true
+	return h.Hijack()
+}
+
+// Unwrap returns the underlying writer for http.ResponseController
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// close finishes compressing the response, writing the held back status if
+// nothing was written
+func (cw *compressWriter) close() {
+	if !cw.started {
+		cw.start(nil)
+	}
+
+	if cw.enc != nil {
+		cw.enc.Close() //nolint,errcheck
+	}
+}
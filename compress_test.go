@@ -0,0 +1,168 @@
+package bogus
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/franela/goblin"
+	"github.com/gomicro/bogus/paths"
+	. "github.com/onsi/gomega"
+)
+
+func TestCompression(t *testing.T) {
+	g := goblin.Goblin(t)
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("Compression", func() {
+		var server *Bogus
+		var client *http.Client
+
+		g.BeforeEach(func() {
+			server = New(WithCompression())
+			client = &http.Client{Transport: &http.Transport{DisableCompression: true}}
+		})
+
+		g.AfterEach(func() {
+			server.Close()
+		})
+
+		do := func(method, path, encoding string, body []byte) (*http.Response, []byte) {
+			req, _ := http.NewRequest(method, server.URL()+path, bytes.NewReader(body))
+			req.Header.Set("Accept-Encoding", encoding)
+			if len(body) != 0 {
+				req.Header.Set("Content-Encoding", encoding)
+			}
+
+			resp, err := client.Do(req)
+			Expect(err).NotTo(HaveOccurred())
+			defer resp.Body.Close()
+
+			respBody, _ := ioutil.ReadAll(resp.Body)
+			return resp, respBody
+		}
+
+		g.It("should compress responses the client accepts compressed", func() {
+			server.AddPath("/page").
+				SetMethods("GET", "HEAD").
+				SetPayload([]byte("<html><body>hello</body></html>"))
+
+			resp, body := do("GET", "/page", "br;q=1, gzip;q=0.8, deflate;q=0.5", nil)
+			Expect(resp.Header.Get("Content-Encoding")).To(Equal("gzip"))
+			Expect(resp.Header.Get("Vary")).To(Equal("Accept-Encoding"))
+			Expect(resp.Header.Get("Content-Type")).To(HavePrefix("text/html"))
+
+			gr, err := gzip.NewReader(bytes.NewReader(body))
+			Expect(err).NotTo(HaveOccurred())
+			plain, _ := ioutil.ReadAll(gr)
+			Expect(string(plain)).To(Equal("<html><body>hello</body></html>"))
+
+			rec := server.HitRecords()[0]
+			Expect(string(rec.ResponseBody)).To(Equal("<html><body>hello</body></html>"))
+			Expect(rec.RawResponseBody).To(Equal(body))
+			Expect(rec.ResponseHeader.Get("Content-Encoding")).To(Equal("gzip"))
+
+			resp, body = do("GET", "/page", "deflate", nil)
+			Expect(resp.Header.Get("Content-Encoding")).To(Equal("deflate"))
+			zr, err := zlib.NewReader(bytes.NewReader(body))
+			Expect(err).NotTo(HaveOccurred())
+			plain, _ = ioutil.ReadAll(zr)
+			Expect(string(plain)).To(Equal("<html><body>hello</body></html>"))
+
+			resp, body = do("GET", "/page", "identity", nil)
+			Expect(resp.Header.Get("Content-Encoding")).To(BeEmpty())
+			Expect(string(body)).To(Equal("<html><body>hello</body></html>"))
+			Expect(server.HitRecords()[2].RawResponseBody).To(BeNil())
+
+			resp, _ = do("HEAD", "/page", "gzip", nil)
+			Expect(resp.Header.Get("Content-Encoding")).To(BeEmpty())
+		})
+
+		g.It("should ignore accepted codings with a q-value out of range", func() {
+			server.AddPath("/page").
+				SetMethods("GET").
+				SetPayload([]byte("hello"))
+
+			resp, _ := do("GET", "/page", "gzip;q=2, deflate;q=0.5", nil)
+			Expect(resp.Header.Get("Content-Encoding")).To(Equal("deflate"))
+
+			resp, body := do("GET", "/page", "gzip;q=-1", nil)
+			Expect(resp.Header.Get("Content-Encoding")).To(BeEmpty())
+			Expect(string(body)).To(Equal("hello"))
+		})
+
+		g.It("should leave responses with their own coding alone", func() {
+			server.AddPath("/encoded").
+				SetMethods("GET").
+				SetHeaders(map[string]string{"Content-Encoding": "br"}).
+				SetPayload([]byte("already encoded"))
+
+			resp, body := do("GET", "/encoded", "gzip", nil)
+			Expect(resp.Header.Get("Content-Encoding")).To(Equal("br"))
+			Expect(string(body)).To(Equal("already encoded"))
+		})
+
+		g.It("should decode compressed request bodies before matching", func() {
+			p := server.AddPath("/upload").
+				SetMethods("POST")
+			p.Expect(paths.BodyEquals([]byte("payload"))).
+				Respond(paths.Response{Status: http.StatusCreated})
+
+			var gz bytes.Buffer
+			gw := gzip.NewWriter(&gz)
+			gw.Write([]byte("payload")) //nolint,errcheck
+			gw.Close()
+
+			resp, _ := do("POST", "/upload", "gzip", gz.Bytes())
+			Expect(resp.StatusCode).To(Equal(http.StatusCreated))
+
+			var zl bytes.Buffer
+			zw := zlib.NewWriter(&zl)
+			zw.Write([]byte("payload")) //nolint,errcheck
+			zw.Close()
+
+			resp, _ = do("POST", "/upload", "deflate", zl.Bytes())
+			Expect(resp.StatusCode).To(Equal(http.StatusCreated))
+
+			var br bytes.Buffer
+			bw := brotli.NewWriter(&br)
+			bw.Write([]byte("payload")) //nolint,errcheck
+			bw.Close()
+
+			resp, _ = do("POST", "/upload", "br", br.Bytes())
+			Expect(resp.StatusCode).To(Equal(http.StatusCreated))
+
+			records := server.HitRecords()
+			Expect(string(records[0].Body)).To(Equal("payload"))
+			Expect(records[0].RawBody).To(Equal(gz.Bytes()))
+			Expect(records[0].Header.Get("Content-Encoding")).To(Equal("gzip"))
+			Expect(records[1].RawBody).To(Equal(zl.Bytes()))
+			Expect(string(records[2].Body)).To(Equal("payload"))
+			Expect(records[2].RawBody).To(Equal(br.Bytes()))
+		})
+
+		g.It("should leave request bodies alone without the option", func() {
+			plain := New()
+			defer plain.Close()
+			plain.AddPath("/upload").SetMethods("POST")
+
+			var gz bytes.Buffer
+			gw := gzip.NewWriter(&gz)
+			gw.Write([]byte("payload")) //nolint,errcheck
+			gw.Close()
+
+			req, _ := http.NewRequest("POST", plain.URL()+"/upload", bytes.NewReader(gz.Bytes()))
+			req.Header.Set("Content-Encoding", "gzip")
+			resp, err := client.Do(req)
+			Expect(err).NotTo(HaveOccurred())
+			resp.Body.Close()
+
+			Expect(plain.HitRecords()[0].Body).To(Equal(gz.Bytes()))
+			Expect(plain.HitRecords()[0].RawBody).To(BeNil())
+		})
+	})
+}
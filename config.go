@@ -0,0 +1,462 @@
+package bogus
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gomicro/bogus/paths"
+	"gopkg.in/yaml.v3"
+)
+
+// Config describes the routes of a bogus server, as read from a YAML or JSON
+// fixture file
+type Config struct {
+	Routes []RouteConfig `yaml:"routes"`
+
+	// dir is where body files are read from
+	dir string
+}
+
+// RouteConfig describes a single route. Routes sharing a path are tried in
+// the order they are listed, and the first one whose methods and matchers are
+// all met answers the request. A route lists either a single response inline
+// or a sequence of responses to reply with in turn. A delay applies only to
+// the responses of the route listing it.
+type RouteConfig struct {
+	Path    string      `yaml:"path"`
+	Methods []string    `yaml:"methods"`
+	Match   MatchConfig `yaml:"match"`
+	Delay   Duration    `yaml:"delay"`
+
+	ResponseConfig `yaml:",inline"`
+	Responses      []ResponseConfig `yaml:"responses"`
+}
+
+// MatchConfig describes the conditions a request must meet for a route to
+// answer it. Query and header values are regular expressions which must match
+// in full.
+type MatchConfig struct {
+	Query   map[string]Pattern `yaml:"query"`
+	Headers map[string]Pattern `yaml:"headers"`
+	Body    string             `yaml:"body"`
+	JSON    interface{}        `yaml:"json"`
+}
+
+// ResponseConfig describes a response. The body is given either inline or as
+// a file, relative to the fixture file it is listed in.
+type ResponseConfig struct {
+	Status   int               `yaml:"status"`
+	Headers  map[string]string `yaml:"headers"`
+	Body     string            `yaml:"body"`
+	BodyFile string            `yaml:"bodyFile"`
+}
+
+// Duration is a delay written as a Go duration string, such as 250ms
+type Duration time.Duration
+
+// Pattern is a regular expression
+type Pattern string
+
+// The fields allowed in each part of a fixture
+var (
+	configFields   = fields("routes")
+	routeFields    = fields("path", "methods", "match", "delay", "status", "headers", "body", "bodyFile", "responses")
+	matchFields    = fields("query", "headers", "body", "json")
+	responseFields = fields("status", "headers", "body", "bodyFile")
+)
+
+func fields(names ...string) map[string]bool {
+	set := make(map[string]bool, len(names))
+	for _, name := range names {
+		set[name] = true
+	}
+
+	return set
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler, parsing a duration string
+func (d *Duration) UnmarshalYAML(value *yaml.Node) error {
+	v, err := time.ParseDuration(value.Value)
+	if err != nil {
+		return fmt.Errorf("line %v: delay is not a valid duration", value.Line)
+	}
+
+	*d = Duration(v)
+	return nil
+}
+
+// ParseConfig parses a YAML or JSON document describing routes. Unknown
+// fields and invalid values are reported along with the line they appear on.
+func ParseConfig(data []byte) (Config, error) {
+	var cfg Config
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return cfg, fmt.Errorf("bogus: invalid config: %v", err)
+	}
+
+	if len(doc.Content) == 0 {
+		return cfg, nil
+	}
+
+	c := &checker{}
+	c.config(doc.Content[0])
+	if len(c.problems) != 0 {
+		return cfg, fmt.Errorf("bogus: invalid config:\n  %v", strings.Join(c.problems, "\n  "))
+	}
+
+	if err := doc.Decode(&cfg); err != nil {
+		if te, ok := err.(*yaml.TypeError); ok {
+			return cfg, fmt.Errorf("bogus: invalid config:\n  %v", strings.Join(te.Errors, "\n  "))
+		}
+
+		return cfg, fmt.Errorf("bogus: invalid config: %v", err)
+	}
+
+	return cfg, nil
+}
+
+// checker walks a parsed fixture before it is decoded, collecting the problems
+// found in it along with the line each is on
+type checker struct {
+	problems []string
+}
+
+func (c *checker) report(n *yaml.Node, problem string) {
+	c.problems = append(c.problems, fmt.Sprintf("line %v: %v", n.Line, problem))
+}
+
+func (c *checker) config(n *yaml.Node) {
+	f := c.mapping(n, configFields)
+
+	if routes, ok := f["routes"]; ok {
+		for _, rt := range c.sequence(routes) {
+			c.route(rt)
+		}
+	}
+}
+
+func (c *checker) route(n *yaml.Node) {
+	f := c.mapping(n, routeFields)
+	if f == nil {
+		return
+	}
+
+	if path, ok := f["path"]; !ok || path.Value == "" {
+		c.report(n, "route is missing a path")
+	}
+
+	if delay, ok := f["delay"]; ok {
+		if _, err := time.ParseDuration(delay.Value); err != nil {
+			c.report(delay, "delay is not a valid duration")
+		}
+	}
+
+	if match, ok := f["match"]; ok {
+		c.match(match)
+	}
+
+	c.response(n, f)
+
+	if resps, ok := f["responses"]; ok {
+		for _, resp := range c.sequence(resps) {
+			c.response(resp, c.mapping(resp, responseFields))
+		}
+	}
+}
+
+func (c *checker) match(n *yaml.Node) {
+	f := c.mapping(n, matchFields)
+
+	for _, name := range []string{"query", "headers"} {
+		if patterns, ok := f[name]; ok {
+			for _, pattern := range c.mapping(patterns, nil) {
+				if _, err := regexp.Compile(pattern.Value); err != nil {
+					c.report(pattern, "pattern is not a valid regular expression")
+				}
+			}
+		}
+	}
+}
+
+// response checks the response fields of a route or of a response listed on
+// one
+func (c *checker) response(n *yaml.Node, f map[string]*yaml.Node) {
+	if status, ok := f["status"]; ok {
+		if v, err := strconv.Atoi(status.Value); err == nil && v != 0 && (v < 100 || v > 599) {
+			c.report(status, "status is not a valid http status")
+		}
+	}
+
+	_, body := f["body"]
+	_, bodyFile := f["bodyFile"]
+	if body && bodyFile {
+		c.report(n, "response has both a body and a bodyFile")
+	}
+}
+
+// mapping returns the values of a mapping node by key, reporting keys that are
+// not among the fields allowed. Any key is allowed if no fields are given.
+func (c *checker) mapping(n *yaml.Node, allowed map[string]bool) map[string]*yaml.Node {
+	if n.Kind == yaml.AliasNode {
+		n = n.Alias
+	}
+
+	if n.Kind != yaml.MappingNode {
+		c.report(n, "expected a mapping")
+		return nil
+	}
+
+	values := make(map[string]*yaml.Node, len(n.Content)/2)
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key := n.Content[i]
+		if allowed != nil && !allowed[key.Value] {
+			c.report(key, "unknown field "+key.Value)
+			continue
+		}
+
+		values[key.Value] = n.Content[i+1]
+	}
+
+	return values
+}
+
+func (c *checker) sequence(n *yaml.Node) []*yaml.Node {
+	if n.Kind == yaml.AliasNode {
+		n = n.Alias
+	}
+
+	if n.Kind != yaml.SequenceNode {
+		c.report(n, "expected a list")
+		return nil
+	}
+
+	return n.Content
+}
+
+// LoadFile reads a YAML or JSON fixture file and returns a new server
+// configured by it and any options provided. Body files are read relative to
+// the fixture file.
+func LoadFile(filename string, opts ...Option) (*Bogus, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.EqualFold(filepath.Ext(filename), ".json") {
+		data, err = jsonAsYAML(data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	cfg, err := ParseConfig(data)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.dir = filepath.Dir(filename)
+
+	return NewFromConfig(cfg, opts...)
+}
+
+// NewFromConfig returns a new server configured by the config and any options
+// provided
+func NewFromConfig(cfg Config, opts ...Option) (*Bogus, error) {
+	b := New(opts...)
+
+	if err := b.Configure(cfg); err != nil {
+		b.Close()
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// Configure adds the routes described by the config to the server. Routes
+// without methods respond to GET.
+func (b *Bogus) Configure(cfg Config) error {
+	type routeSpec struct {
+		path     *paths.Path
+		methods  []string
+		matchers [][]paths.Matcher
+		resps    [][]paths.Response
+	}
+
+	routes := map[string]*routeSpec{}
+	order := []string{}
+
+	for i, rc := range cfg.Routes {
+		if rc.Path == "" {
+			return fmt.Errorf("bogus: route %v is missing a path", i)
+		}
+
+		rt, ok := routes[rc.Path]
+		if !ok {
+			rt = &routeSpec{path: b.AddPath(rc.Path)}
+			routes[rc.Path] = rt
+			order = append(order, rc.Path)
+		}
+
+		methods := rc.Methods
+		if len(methods) == 0 {
+			methods = []string{http.MethodGet}
+		}
+		rt.methods = append(rt.methods, methods...)
+
+		matchers, err := rc.Match.matchers(methods)
+		if err != nil {
+			return fmt.Errorf("bogus: route %v: %v", rc.Path, err)
+		}
+
+		configs := rc.Responses
+		if len(configs) == 0 {
+			configs = []ResponseConfig{rc.ResponseConfig}
+		}
+
+		resps := make([]paths.Response, len(configs))
+		for j, c := range configs {
+			resps[j], err = c.response(cfg.dir)
+			if err != nil {
+				return fmt.Errorf("bogus: route %v: %v", rc.Path, err)
+			}
+
+			resps[j].Delay = time.Duration(rc.Delay)
+		}
+
+		rt.matchers = append(rt.matchers, matchers)
+		rt.resps = append(rt.resps, resps)
+	}
+
+	for _, path := range order {
+		rt := routes[path]
+		rt.path.SetMethods(rt.methods...)
+
+		for i := range rt.matchers {
+			rt.path.Expect(rt.matchers[i]...).
+				Respond(rt.resps[i]...)
+		}
+	}
+
+	return nil
+}
+
+func (mc MatchConfig) matchers(methods []string) ([]paths.Matcher, error) {
+	allowed := map[string]bool{}
+	for _, m := range methods {
+		allowed[strings.ToUpper(m)] = true
+	}
+
+	matchers := []paths.Matcher{
+		paths.MatcherFunc(fmt.Sprintf("method is one of %v", methods), func(r *http.Request, _ []byte) bool {
+			return allowed[strings.ToUpper(r.Method)]
+		}),
+	}
+
+	for name, pattern := range mc.Query {
+		if _, err := regexp.Compile(string(pattern)); err != nil {
+			return nil, fmt.Errorf("query %v: %v", name, err)
+		}
+
+		matchers = append(matchers, paths.QueryMatches(name, string(pattern)))
+	}
+
+	for name, pattern := range mc.Headers {
+		if _, err := regexp.Compile(string(pattern)); err != nil {
+			return nil, fmt.Errorf("header %v: %v", name, err)
+		}
+
+		matchers = append(matchers, paths.HeaderMatches(name, string(pattern)))
+	}
+
+	if mc.Body != "" {
+		matchers = append(matchers, paths.BodyEquals([]byte(mc.Body)))
+	}
+
+	if mc.JSON != nil {
+		doc, err := json.Marshal(jsonCompatible(mc.JSON))
+		if err != nil {
+			return nil, fmt.Errorf("json: %v", err)
+		}
+
+		matchers = append(matchers, paths.BodyJSON(doc))
+	}
+
+	return matchers, nil
+}
+
+func (rc ResponseConfig) response(dir string) (paths.Response, error) {
+	payload := []byte(rc.Body)
+
+	if rc.BodyFile != "" {
+		filename := rc.BodyFile
+		if !filepath.IsAbs(filename) {
+			filename = filepath.Join(dir, filename)
+		}
+
+		var err error
+		payload, err = ioutil.ReadFile(filename)
+		if err != nil {
+			return paths.Response{}, err
+		}
+	}
+
+	return paths.Response{
+		Status:  rc.Status,
+		Headers: rc.Headers,
+		Payload: payload,
+	}, nil
+}
+
+// jsonCompatible converts the maps produced by the yaml decoder, which are
+// keyed by interface{}, into maps the json encoder accepts
+func jsonCompatible(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, val := range t {
+			m[fmt.Sprint(k)] = jsonCompatible(val)
+		}
+		return m
+
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, val := range t {
+			m[k] = jsonCompatible(val)
+		}
+		return m
+
+	case []interface{}:
+		s := make([]interface{}, len(t))
+		for i, val := range t {
+			s[i] = jsonCompatible(val)
+		}
+		return s
+	}
+
+	return v
+}
+
+// jsonAsYAML checks a JSON document is well formed, reporting the line of any
+// syntax error, and prepares it for the yaml decoder. Tabs can only appear
+// between tokens in valid JSON, and are replaced since YAML forbids them.
+func jsonAsYAML(data []byte) ([]byte, error) {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		if se, ok := err.(*json.SyntaxError); ok {
+			line := 1 + strings.Count(string(data[:se.Offset]), "\n")
+			return nil, fmt.Errorf("bogus: invalid config:\n  line %v: %v", line, se)
+		}
+
+		return nil, fmt.Errorf("bogus: invalid config: %v", err)
+	}
+
+	return []byte(strings.ReplaceAll(string(data), "\t", " ")), nil
+}
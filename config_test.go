@@ -0,0 +1,208 @@
+package bogus
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+)
+
+func TestConfig(t *testing.T) {
+	g := goblin.Goblin(t)
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("Fixture Files", func() {
+		var dir string
+
+		g.BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "bogus")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		g.AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		write := func(name, content string) string {
+			filename := filepath.Join(dir, name)
+			Expect(ioutil.WriteFile(filename, []byte(content), 0644)).To(Succeed())
+			return filename
+		}
+
+		do := func(method, url, body string, header http.Header) (int, http.Header, string) {
+			req, _ := http.NewRequest(method, url, bytes.NewBufferString(body))
+			for k, v := range header {
+				req.Header[k] = v
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			Expect(err).NotTo(HaveOccurred())
+			defer resp.Body.Close()
+
+			respBody, _ := ioutil.ReadAll(resp.Body)
+			return resp.StatusCode, resp.Header, string(respBody)
+		}
+
+		g.It("should configure routes from a yaml file", func() {
+			write("user.json", `{"id":"42"}`)
+			filename := write("fixture.yaml", `
+routes:
+  - path: /users/{id}
+    bodyFile: user.json
+    headers:
+      Content-Type: application/json
+  - path: /users/{id}
+    methods: [PUT]
+    match:
+      headers:
+        Content-Type: application/json.*
+      json:
+        name: alice
+    status: 204
+  - path: /search
+    match:
+      query:
+        q: go.*
+    responses:
+      - body: first
+      - body: second
+    delay: 10ms
+`)
+
+			server, err := LoadFile(filename)
+			Expect(err).NotTo(HaveOccurred())
+			defer server.Close()
+
+			status, header, body := do("GET", server.URL()+"/users/42", "", nil)
+			Expect(status).To(Equal(http.StatusOK))
+			Expect(header.Get("Content-Type")).To(Equal("application/json"))
+			Expect(body).To(Equal(`{"id":"42"}`))
+
+			json := http.Header{"Content-Type": []string{"application/json; charset=utf-8"}}
+			status, _, _ = do("PUT", server.URL()+"/users/42", `{ "name": "alice" }`, json)
+			Expect(status).To(Equal(http.StatusNoContent))
+
+			status, _, _ = do("PUT", server.URL()+"/users/42", `{"name":"bob"}`, json)
+			Expect(status).To(Equal(http.StatusForbidden))
+
+			status, _, _ = do("DELETE", server.URL()+"/users/42", "", nil)
+			Expect(status).To(Equal(http.StatusForbidden))
+
+			start := time.Now()
+			_, _, body = do("GET", server.URL()+"/search?q=golang", "", nil)
+			Expect(body).To(Equal("first"))
+			Expect(time.Since(start)).To(BeNumerically(">=", 10*time.Millisecond))
+
+			_, _, body = do("GET", server.URL()+"/search?q=golang", "", nil)
+			Expect(body).To(Equal("second"))
+
+			status, _, _ = do("GET", server.URL()+"/search?q=rust", "", nil)
+			Expect(status).To(Equal(http.StatusForbidden))
+		})
+
+		g.It("should configure routes from a json file", func() {
+			filename := write("fixture.json", "{\n\t\"routes\": [\n\t\t{\"path\": \"/health\", \"status\": 200, \"body\": \"ok\"}\n\t]\n}\n")
+
+			server, err := LoadFile(filename)
+			Expect(err).NotTo(HaveOccurred())
+			defer server.Close()
+
+			status, _, body := do("GET", server.URL()+"/health", "", nil)
+			Expect(status).To(Equal(http.StatusOK))
+			Expect(body).To(Equal("ok"))
+		})
+
+		g.It("should report schema errors with line numbers", func() {
+			_, err := ParseConfig([]byte(`
+routes:
+  - path: /a
+    delay: soon
+  - methods: [GET]
+  - path: /b
+    status: 1000
+  - path: /c
+    match:
+      query:
+        q: "("
+  - path: /d
+    unknown: true
+  - path: /e
+    responses:
+      - status: 200
+      - body: a
+        bodyFile: a.json
+`))
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("line 4: delay is not a valid duration"))
+			Expect(err.Error()).To(ContainSubstring("line 5: route is missing a path"))
+			Expect(err.Error()).To(ContainSubstring("line 7: status is not a valid http status"))
+			Expect(err.Error()).To(ContainSubstring("line 11: pattern is not a valid regular expression"))
+			Expect(err.Error()).To(ContainSubstring("line 13: unknown field unknown"))
+			Expect(err.Error()).To(ContainSubstring("line 17: response has both a body and a bodyFile"))
+
+			_, err = ParseConfig([]byte(`
+routes:
+  - path: /a
+    status: ok
+`))
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("line 4: cannot unmarshal"))
+
+			filename := write("broken.json", "{\n  \"routes\": [\n    {\"path\": \"/a\",}\n  ]\n}\n")
+			_, err = LoadFile(filename)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("line 3:"))
+		})
+
+		g.It("should configure an existing server", func() {
+			server := New()
+			defer server.Close()
+
+			err := server.Configure(Config{Routes: []RouteConfig{{
+				Path:           "/status",
+				Methods:        []string{"HEAD", "GET"},
+				ResponseConfig: ResponseConfig{Status: http.StatusAccepted},
+			}}})
+			Expect(err).NotTo(HaveOccurred())
+
+			status, _, _ := do("HEAD", server.URL()+"/status", "", nil)
+			Expect(status).To(Equal(http.StatusAccepted))
+		})
+
+		g.It("should only delay the route listing the delay", func() {
+			cfg, err := ParseConfig([]byte(`
+routes:
+  - path: /a
+    match:
+      query:
+        slow: "1"
+    body: slow
+    delay: 300ms
+  - path: /a
+    body: fast
+`))
+			Expect(err).NotTo(HaveOccurred())
+
+			server, err := NewFromConfig(cfg)
+			Expect(err).NotTo(HaveOccurred())
+			defer server.Close()
+
+			start := time.Now()
+			_, _, body := do("GET", server.URL()+"/a", "", nil)
+			Expect(body).To(Equal("fast"))
+			Expect(time.Since(start)).To(BeNumerically("<", 300*time.Millisecond))
+
+			start = time.Now()
+			_, _, body = do("GET", server.URL()+"/a?slow=1", "", nil)
+			Expect(body).To(Equal("slow"))
+			Expect(time.Since(start)).To(BeNumerically(">=", 300*time.Millisecond))
+		})
+	})
+}
@@ -0,0 +1,103 @@
+package bogus
+
+import (
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/franela/goblin"
+	"github.com/gomicro/bogus/paths"
+	. "github.com/onsi/gomega"
+)
+
+func TestFaults(t *testing.T) {
+	g := goblin.Goblin(t)
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("Fault Injection", func() {
+		get := func(server *Bogus, path string) (*http.Response, []byte, error) {
+			client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+
+			resp, err := client.Get(server.URL() + path)
+			if err != nil {
+				return nil, nil, err
+			}
+			defer resp.Body.Close()
+
+			body, err := ioutil.ReadAll(resp.Body)
+			return resp, body, err
+		}
+
+		g.It("should inject faults into a path's responses", func() {
+			server := New()
+			defer server.Close()
+
+			server.AddPath("/drop").SetMethods("GET").SetFault(paths.DropConnection, 1)
+			server.AddPath("/reset").SetMethods("GET").SetFault(paths.ResetAfterHeaders, 1)
+			server.AddPath("/truncate").SetMethods("GET").SetFault(paths.TruncateBody, 1)
+			server.AddPath("/chunks").SetMethods("GET").SetFault(paths.MalformedChunks, 1)
+
+			_, _, err := get(server, "/drop")
+			Expect(err).To(HaveOccurred())
+
+			resp, _, err := get(server, "/reset")
+			Expect(err).To(HaveOccurred())
+			if resp != nil {
+				Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			}
+
+			resp, body, err := get(server, "/truncate")
+			Expect(err).To(MatchError(ContainSubstring("unexpected EOF")))
+			Expect(resp.ContentLength).To(Equal(int64(64)))
+			Expect(string(body)).To(Equal("truncated"))
+
+			_, _, err = get(server, "/chunks")
+			Expect(err).To(HaveOccurred())
+
+			records := server.HitRecords()
+			Expect(records).To(HaveLen(4))
+			Expect(records[0].Fault).To(Equal(paths.DropConnection))
+			Expect(records[0].Status).To(Equal(0))
+			Expect(records[2].Fault).To(Equal(paths.TruncateBody))
+		})
+
+		g.It("should inject faults server wide", func() {
+			server := New(WithFault(paths.DropConnection, 1))
+			defer server.Close()
+
+			server.AddPath("/ok").SetMethods("GET")
+
+			_, _, err := get(server, "/ok")
+			Expect(err).To(HaveOccurred())
+			Expect(server.HitRecords()[0].Fault).To(Equal(paths.DropConnection))
+		})
+
+		g.It("should release stalled requests when the server closes", func() {
+			server := New()
+			server.AddPath("/stall").SetMethods("GET").SetFault(paths.Stall, 1)
+
+			errs := make(chan error, 1)
+			go func() {
+				_, _, err := get(server, "/stall")
+				errs <- err
+			}()
+
+			Eventually(server.Hits).Should(Equal(1))
+
+			closed := make(chan struct{})
+			go func() {
+				server.Close()
+				close(closed)
+			}()
+
+			Eventually(closed, time.Second).Should(BeClosed())
+			Eventually(errs, time.Second).Should(Receive(HaveOccurred()))
+
+			rec := server.HitRecords()[0]
+			Expect(rec.Fault).To(Equal(paths.Stall))
+			Expect(rec.Status).To(Equal(0))
+			Expect(rec.ResponseHeader).To(BeNil())
+		})
+	})
+}
@@ -3,8 +3,10 @@ module github.com/gomicro/bogus
 go 1.16
 
 require (
+	github.com/andybalholm/brotli v1.1.0
 	github.com/franela/goblin v0.0.0-20131017120451-74c9fe110d4b
 	github.com/gomicro/penname v0.0.2
 	github.com/onsi/ginkgo v1.16.4 // indirect
 	github.com/onsi/gomega v1.10.1
+	gopkg.in/yaml.v3 v3.0.1
 )
@@ -0,0 +1,27 @@
+//go:build go1.24
+// +build go1.24
+
+package bogus
+
+import (
+	"net/http"
+	"net/http/httptest"
+)
+
+// enableH2C lets the server accept cleartext HTTP/2 alongside HTTP/1.1
+func enableH2C(s *httptest.Server) error {
+	var protocols http.Protocols
+	protocols.SetHTTP1(true)
+	protocols.SetUnencryptedHTTP2(true)
+
+	s.Config.Protocols = &protocols
+	return nil
+}
+
+// h2cTransport makes the transport speak cleartext HTTP/2 with prior knowledge
+func h2cTransport(tr *http.Transport) {
+	var protocols http.Protocols
+	protocols.SetUnencryptedHTTP2(true)
+
+	tr.Protocols = &protocols
+}
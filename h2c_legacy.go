@@ -0,0 +1,19 @@
+//go:build !go1.24
+// +build !go1.24
+
+package bogus
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+)
+
+// enableH2C is unsupported before go1.24, which added unencrypted HTTP/2 to
+// the standard library
+func enableH2C(s *httptest.Server) error {
+	return errors.New("h2c requires go1.24 or later, but this is " + runtime.Version())
+}
+
+func h2cTransport(tr *http.Transport) {}
@@ -0,0 +1,59 @@
+//go:build go1.24
+// +build go1.24
+
+package bogus
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+)
+
+func TestHTTP2(t *testing.T) {
+	g := goblin.Goblin(t)
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("HTTP/2", func() {
+		g.It("should serve http2 over tls and record the protocol", func() {
+			server := New(WithHTTP2())
+			defer server.Close()
+
+			server.AddPath("/").
+				SetMethods("GET")
+
+			resp, err := server.Client().Get(server.URL())
+			Expect(err).NotTo(HaveOccurred())
+			resp.Body.Close()
+
+			Expect(resp.Proto).To(Equal("HTTP/2.0"))
+			Expect(server.HitRecords()[0].Proto).To(Equal("HTTP/2.0"))
+		})
+
+		g.It("should serve h2c alongside http1", func() {
+			server := New(WithH2C())
+			defer server.Close()
+
+			server.AddPath("/").
+				SetMethods("GET")
+
+			resp, err := server.Client().Get(server.URL())
+			Expect(err).NotTo(HaveOccurred())
+			resp.Body.Close()
+
+			Expect(resp.Proto).To(Equal("HTTP/2.0"))
+
+			resp, err = http.Get(server.URL())
+			Expect(err).NotTo(HaveOccurred())
+			resp.Body.Close()
+
+			Expect(resp.Proto).To(Equal("HTTP/1.1"))
+
+			records := server.HitRecords()
+			Expect(records).To(HaveLen(2))
+			Expect(records[0].Proto).To(Equal("HTTP/2.0"))
+			Expect(records[1].Proto).To(Equal("HTTP/1.1"))
+		})
+	})
+}
@@ -0,0 +1,82 @@
+// Package accept parses Accept style request headers, as used by content
+// negotiation and response compression
+package accept
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Range is a single entry of an Accept style header
+type Range struct {
+	Value string
+	Q     float64
+}
+
+// Parse parses the comma separated entries of Accept style headers,
+// returning nil if there are none. Entries with a q-value that is malformed
+// or outside of 0 to 1 are skipped.
+func Parse(values []string) []Range {
+	var ranges []Range
+
+	for _, v := range values {
+		for _, entry := range strings.Split(v, ",") {
+			parts := strings.Split(entry, ";")
+			value := strings.ToLower(strings.TrimSpace(parts[0]))
+			if value == "" {
+				continue
+			}
+
+			q, ok := 1.0, true
+			for _, param := range parts[1:] {
+				kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+				if len(kv) == 2 && strings.EqualFold(strings.TrimSpace(kv[0]), "q") {
+					var err error
+					q, err = strconv.ParseFloat(strings.TrimSpace(kv[1]), 64)
+					ok = err == nil && q >= 0 && q <= 1
+				}
+			}
+
+			if ok {
+				ranges = append(ranges, Range{Value: value, Q: q})
+			}
+		}
+	}
+
+	return ranges
+}
+
+// EncodingQuality returns the quality the Accept-Encoding ranges give the
+// content coding, where no coding is identity. Without any ranges every coding
+// is acceptable. Identity is acceptable unless it is explicitly refused.
+func EncodingQuality(ranges []Range, encoding string) float64 {
+	if ranges == nil {
+		return 1
+	}
+
+	encoding = strings.ToLower(encoding)
+	if encoding == "" {
+		encoding = "identity"
+	}
+
+	wildcard := -1.0
+	for _, r := range ranges {
+		if r.Value == encoding {
+			return r.Q
+		}
+
+		if r.Value == "*" {
+			wildcard = r.Q
+		}
+	}
+
+	if wildcard >= 0 {
+		return wildcard
+	}
+
+	if encoding == "identity" {
+		return 1
+	}
+
+	return 0
+}
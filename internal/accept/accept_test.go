@@ -0,0 +1,42 @@
+package accept
+
+import (
+	"testing"
+
+	"github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+)
+
+func TestAccept(t *testing.T) {
+	g := goblin.Goblin(t)
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("Accept", func() {
+		g.It("should parse entries and their q-values", func() {
+			ranges := Parse([]string{"text/html, application/json;q=0.5", "*/*;Q=0"})
+			Expect(ranges).To(Equal([]Range{
+				{Value: "text/html", Q: 1},
+				{Value: "application/json", Q: 0.5},
+				{Value: "*/*", Q: 0},
+			}))
+		})
+
+		g.It("should skip q-values that are malformed or out of range", func() {
+			ranges := Parse([]string{"gzip;q=2, br;q=-1, deflate;q=high, identity"})
+			Expect(ranges).To(Equal([]Range{{Value: "identity", Q: 1}}))
+
+			Expect(Parse([]string{"gzip;q=2"})).To(BeNil())
+		})
+
+		g.It("should give encodings the quality they are accepted with", func() {
+			ranges := Parse([]string{"gzip;q=0.8, *;q=0.1"})
+			Expect(EncodingQuality(ranges, "gzip")).To(Equal(0.8))
+			Expect(EncodingQuality(ranges, "br")).To(Equal(0.1))
+
+			ranges = Parse([]string{"gzip"})
+			Expect(EncodingQuality(ranges, "br")).To(Equal(0.0))
+			Expect(EncodingQuality(ranges, "")).To(Equal(1.0))
+			Expect(EncodingQuality(nil, "br")).To(Equal(1.0))
+		})
+	})
+}
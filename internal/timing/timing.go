@@ -0,0 +1,26 @@
+// Package timing holds helpers for the delays shared by the server and its
+// paths
+package timing
+
+import (
+	"net/http"
+	"time"
+)
+
+// Wait pauses for the delay provided and reports whether it ran its course
+// rather than being cut short by the request's context ending
+func Wait(r *http.Request, delay time.Duration) bool {
+	if delay <= 0 {
+		return true
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-r.Context().Done():
+		return false
+	}
+}
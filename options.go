@@ -0,0 +1,188 @@
+package bogus
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http/httputil"
+	"time"
+
+	"github.com/gomicro/bogus/paths"
+)
+
+// Option configures a bogus server as it is created
+type Option func(*config)
+
+type config struct {
+	tls                bool
+	certificates       []tls.Certificate
+	ca                 *tls.Certificate
+	clientCAs          *x509.CertPool
+	clientCertificates []tls.Certificate
+	http2              bool
+	h2c                bool
+
+	address      string
+	listener     net.Listener
+	unstarted    bool
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+	idleTimeout  time.Duration
+
+	proxy *httputil.ReverseProxy
+
+	latency          paths.Latency
+	fault            paths.Fault
+	faultProbability float64
+	seed             *int64
+
+	compression bool
+}
+
+// WithTLS serves over TLS using a certificate generated by the httptest
+// package. Use Client to get a client which trusts it.
+func WithTLS() Option {
+	return func(c *config) {
+		c.tls = true
+	}
+}
+
+// WithCertificate serves over TLS using the certificate provided, such as one
+// loaded with tls.LoadX509KeyPair
+func WithCertificate(cert tls.Certificate) Option {
+	return func(c *config) {
+		c.tls = true
+		c.certificates = append(c.certificates, cert)
+	}
+}
+
+// WithCA serves over TLS using a certificate for localhost issued by the
+// certificate authority provided, so clients already trusting the authority
+// will trust the server. The authority must include its private key.
+func WithCA(ca tls.Certificate) Option {
+	return func(c *config) {
+		c.tls = true
+		c.ca = &ca
+	}
+}
+
+// WithClientCAs serves over mutual TLS, requiring clients to present a
+// certificate issued by one of the authorities in the pool provided
+func WithClientCAs(pool *x509.CertPool) Option {
+	return func(c *config) {
+		c.tls = true
+		c.clientCAs = pool
+	}
+}
+
+// WithClientCertificate sets a certificate for the client returned by Client
+// to present to the server, for use alongside WithClientCAs
+func WithClientCertificate(cert tls.Certificate) Option {
+	return func(c *config) {
+		c.clientCertificates = append(c.clientCertificates, cert)
+	}
+}
+
+// WithHTTP2 serves HTTP/2 over TLS, falling back to HTTP/1.1 for clients which
+// do not negotiate it. Use Client to get a client which negotiates HTTP/2.
+func WithHTTP2() Option {
+	return func(c *config) {
+		c.tls = true
+		c.http2 = true
+	}
+}
+
+// WithH2C serves cleartext HTTP/2 to clients with prior knowledge of it,
+// alongside HTTP/1.1. Use Client to get a client which speaks it. It requires
+// go1.24 or later; on older toolchains New fails, closing the server first,
+// like it does when it cannot listen.
+func WithH2C() Option {
+	return func(c *config) {
+		c.h2c = true
+	}
+}
+
+// WithAddress listens on the host and port provided, such as 127.0.0.1:8080,
+// instead of a random port on the loopback interface
+func WithAddress(address string) Option {
+	return func(c *config) {
+		c.address = address
+	}
+}
+
+// WithListener serves on the listener provided, such as one listening on a
+// unix socket. The listener is closed along with the server.
+func WithListener(listener net.Listener) Option {
+	return func(c *config) {
+		c.listener = listener
+	}
+}
+
+// WithUnstarted creates the server without starting it, so it may be
+// configured further before Start is called
+func WithUnstarted() Option {
+	return func(c *config) {
+		c.unstarted = true
+	}
+}
+
+// WithReadTimeout sets the maximum duration for the server to read an entire
+// request
+func WithReadTimeout(timeout time.Duration) Option {
+	return func(c *config) {
+		c.readTimeout = timeout
+	}
+}
+
+// WithWriteTimeout sets the maximum duration for the server to write a
+// response
+func WithWriteTimeout(timeout time.Duration) Option {
+	return func(c *config) {
+		c.writeTimeout = timeout
+	}
+}
+
+// WithIdleTimeout sets the maximum duration the server keeps an idle
+// connection open
+func WithIdleTimeout(timeout time.Duration) Option {
+	return func(c *config) {
+		c.idleTimeout = timeout
+	}
+}
+
+// WithLatency delays every request to the server by the latency provided,
+// such as paths.Normal(50*time.Millisecond, 10*time.Millisecond), before it
+// is routed. Paths may add latency of their own with SetLatency.
+func WithLatency(latency paths.Latency) Option {
+	return func(c *config) {
+		c.latency = latency
+	}
+}
+
+// WithFault injects the fault provided into responses to requests to the
+// server with the probability provided, from 0 to 1, before they are routed.
+// Paths may inject faults of their own with SetFault.
+func WithFault(fault paths.Fault, probability float64) Option {
+	return func(c *config) {
+		c.fault = fault
+		c.faultProbability = probability
+	}
+}
+
+// WithSeed seeds the source random latencies and faults are drawn from, so
+// they are the same on every run. Paths are seeded in the order they are
+// added.
+func WithSeed(seed int64) Option {
+	return func(c *config) {
+		c.seed = &seed
+	}
+}
+
+// WithCompression compresses responses with gzip or deflate when the client
+// accepts them, and decodes request bodies sent compressed with gzip, deflate,
+// or br before they are matched and recorded.
+func WithCompression() Option {
+	return func(c *config) {
+		c.compression = true
+	}
+}
@@ -0,0 +1,163 @@
+package bogus
+
+import (
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/franela/goblin"
+	"github.com/gomicro/bogus/paths"
+	. "github.com/onsi/gomega"
+)
+
+type latencyFunc func(rng *rand.Rand) time.Duration
+
+func (f latencyFunc) Duration(rng *rand.Rand) time.Duration {
+	return f(rng)
+}
+
+func TestOptions(t *testing.T) {
+	g := goblin.Goblin(t)
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("Options", func() {
+		g.It("should listen on a fixed address", func() {
+			l, err := net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).NotTo(HaveOccurred())
+			address := l.Addr().String()
+			l.Close()
+
+			server := New(WithAddress(address))
+			defer server.Close()
+
+			Expect(server.URL()).To(Equal("http://" + address))
+
+			host, port := server.HostPort()
+			Expect(net.JoinHostPort(host, port)).To(Equal(address))
+
+			server.AddPath("/").
+				SetMethods("GET")
+
+			resp, err := server.Client().Get(server.URL())
+			Expect(err).NotTo(HaveOccurred())
+			resp.Body.Close()
+			Expect(server.Hits()).To(Equal(1))
+		})
+
+		g.It("should create unstarted servers", func() {
+			server := New(WithUnstarted())
+			defer server.Close()
+
+			Expect(server.URL()).To(BeEmpty())
+
+			host, port := server.HostPort()
+			Expect(host).To(BeEmpty())
+			Expect(port).To(BeEmpty())
+
+			server.AddPath("/").
+				SetMethods("GET")
+			server.Start()
+
+			Expect(server.URL()).To(HavePrefix("http://127.0.0.1:"))
+
+			resp, err := server.Client().Get(server.URL())
+			Expect(err).NotTo(HaveOccurred())
+			resp.Body.Close()
+			Expect(server.Hits()).To(Equal(1))
+		})
+
+		g.It("should serve on a provided unix socket listener", func() {
+			dir, err := ioutil.TempDir("", "bogus")
+			Expect(err).NotTo(HaveOccurred())
+			defer os.RemoveAll(dir)
+
+			l, err := net.Listen("unix", filepath.Join(dir, "bogus.sock"))
+			Expect(err).NotTo(HaveOccurred())
+
+			server := New(WithListener(l))
+			defer server.Close()
+
+			Expect(server.URL()).To(Equal("http://localhost"))
+
+			server.AddPath("/sock").
+				SetMethods("GET").
+				SetPayload([]byte("over a socket"))
+
+			resp, err := server.Client().Get(server.URL() + "/sock")
+			Expect(err).NotTo(HaveOccurred())
+			defer resp.Body.Close()
+
+			body, _ := ioutil.ReadAll(resp.Body)
+			Expect(string(body)).To(Equal("over a socket"))
+		})
+
+		g.It("should set server timeouts", func() {
+			server := New(
+				WithReadTimeout(time.Second),
+				WithWriteTimeout(2*time.Second),
+				WithIdleTimeout(3*time.Second),
+			)
+			defer server.Close()
+
+			Expect(server.server.Config.ReadTimeout).To(Equal(time.Second))
+			Expect(server.server.Config.WriteTimeout).To(Equal(2 * time.Second))
+			Expect(server.server.Config.IdleTimeout).To(Equal(3 * time.Second))
+		})
+
+		g.It("should delay every request and record clients giving up", func() {
+			server := New(WithLatency(paths.Fixed(time.Minute)))
+			defer server.Close()
+
+			server.AddPath("/slow").SetMethods("GET")
+
+			client := server.Client()
+			client.Timeout = 20 * time.Millisecond
+
+			_, err := client.Get(server.URL() + "/slow")
+			Expect(err).To(HaveOccurred())
+
+			Eventually(func() bool {
+				records := server.HitRecords()
+				return len(records) == 1 && records[0].Canceled
+			}).Should(BeTrue())
+
+			rec := server.HitRecords()[0]
+			Expect(rec.Status).To(Equal(0))
+			Expect(rec.ResponseHeader).To(BeNil())
+		})
+
+		g.It("should seed path latencies from the server", func() {
+			draws := func(seed int64) []int64 {
+				server := New(WithSeed(seed))
+				defer server.Close()
+
+				drawn := []int64{}
+				for _, pattern := range []string{"/a", "/b"} {
+					server.AddPath(pattern).
+						SetMethods("GET").
+						SetLatency(latencyFunc(func(rng *rand.Rand) time.Duration {
+							drawn = append(drawn, rng.Int63())
+							return 0
+						}))
+				}
+
+				for _, pattern := range []string{"/a", "/b", "/a"} {
+					resp, err := http.Get(server.URL() + pattern)
+					Expect(err).NotTo(HaveOccurred())
+					resp.Body.Close()
+				}
+
+				return drawn
+			}
+
+			Expect(draws(1)).To(HaveLen(3))
+			Expect(draws(1)).To(Equal(draws(1)))
+			Expect(draws(1)).NotTo(Equal(draws(2)))
+		})
+	})
+}
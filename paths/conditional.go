@@ -0,0 +1,164 @@
+package paths
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// conditions holds what a path needs to answer conditional requests
+type conditions struct {
+	etag         string
+	generate     bool
+	lastModified time.Time
+}
+
+// SetETag sets the entity tag sent with the path's responses and compared
+// against the If-Match and If-None-Match headers of requests, and returns the
+// path for additional configuration. Tags are quoted if they are not already,
+// and may be marked weak with a W/ prefix. An empty tag generates a strong
+// tag from each response's payload instead, which leaves responses written by
+// a handler, responder, or stream without a tag, as their payload is not known
+// until they are written. Requests answered with 304 Not Modified or 412
+// Precondition Failed do not use up a queued response or count as a hit.
+func (p *Path) SetETag(etag string) *Path {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.conditions.generate = etag == ""
+	p.conditions.etag = quoteETag(etag)
+	return p
+}
+
+// SetLastModified sets the modification time sent with the path's responses
+// and compared against the If-Modified-Since and If-Unmodified-Since headers
+// of requests, and returns the path for additional configuration
+func (p *Path) SetLastModified(t time.Time) *Path {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.conditions.lastModified = t.UTC().Truncate(time.Second)
+	return p
+}
+
+// enabled reports whether an entity tag or modification time can be sent with
+// a response. Tags generated from payloads cannot be for dynamic responses,
+// which are written by a handler, responder, or stream.
+func (c conditions) enabled(dynamic bool) bool {
+	return c.etag != "" || (c.generate && !dynamic) || !c.lastModified.IsZero()
+}
+
+// validators sets the entity tag and modification time headers on the
+// response
+func (c conditions) validators(w http.ResponseWriter, etag string) {
+	if etag != "" {
+		w.Header().Set("ETag", etag)
+	}
+
+	if !c.lastModified.IsZero() {
+		w.Header().Set("Last-Modified", c.lastModified.Format(http.TimeFormat))
+	}
+}
+
+// evaluate returns the status to short circuit the request with, 304 Not
+// Modified or 412 Precondition Failed, or zero if the full response should be
+// sent. The preconditions are checked in the order RFC 9110 gives.
+func (c conditions) evaluate(r *http.Request, etag string) int {
+	safe := r.Method == http.MethodGet || r.Method == http.MethodHead
+
+	if im := r.Header.Get("If-Match"); im != "" {
+		if !matchETag(im, etag, false) {
+			return http.StatusPreconditionFailed
+		}
+	} else if t, ok := headerTime(r, "If-Unmodified-Since"); ok && !c.lastModified.IsZero() {
+		if c.lastModified.After(t) {
+			return http.StatusPreconditionFailed
+		}
+	}
+
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		if !matchETag(inm, etag, true) {
+			return 0
+		}
+
+		if safe {
+			return http.StatusNotModified
+		}
+
+		return http.StatusPreconditionFailed
+	}
+
+	if t, ok := headerTime(r, "If-Modified-Since"); ok && safe && !c.lastModified.IsZero() {
+		if !c.lastModified.After(t) {
+			return http.StatusNotModified
+		}
+	}
+
+	return 0
+}
+
+// matchETag reports whether the list of entity tags in a conditional header
+// matches the tag provided, using the weak comparison if asked to and the
+// strong one otherwise
+func matchETag(list, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+
+		if etag == "" {
+			continue
+		}
+
+		if weak {
+			if strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+
+			continue
+		}
+
+		if !strings.HasPrefix(candidate, "W/") && candidate == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// generateETag returns a strong entity tag derived from the payload
+func generateETag(payload []byte) string {
+	sum := sha1.Sum(payload)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+func quoteETag(etag string) string {
+	if etag == "" || strings.HasPrefix(etag, `"`) || strings.HasPrefix(etag, `W/"`) {
+		return etag
+	}
+
+	if strings.HasPrefix(etag, "W/") {
+		return `W/"` + strings.TrimPrefix(etag, "W/") + `"`
+	}
+
+	return `"` + etag + `"`
+}
+
+// headerTime parses the HTTP date in the header, reporting false if it is
+// missing or malformed
+func headerTime(r *http.Request, name string) (time.Time, bool) {
+	v := r.Header.Get(name)
+	if v == "" {
+		return time.Time{}, false
+	}
+
+	t, err := http.ParseTime(v)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return t, true
+}
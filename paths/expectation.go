@@ -0,0 +1,73 @@
+package paths
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Expectation represents a set of matchers on a path and the responses to
+// reply with when a request satisfies all of them
+type Expectation struct {
+	hits int64
+
+	mu        *sync.RWMutex
+	matchers  []Matcher
+	responses []Response
+	served    int
+}
+
+// Respond sets the responses for requests meeting the expectation and returns
+// the expectation for additional configuration. Several responses are replied
+// with in order, one per request, repeating the last once they are used up.
+func (e *Expectation) Respond(responses ...Response) *Expectation {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.responses = responses
+	e.served = 0
+	return e
+}
+
+// Hits returns the number of requests that have met the expectation
+func (e *Expectation) Hits() int {
+	return int(atomic.LoadInt64(&e.hits))
+}
+
+// String returns a description of the expectation's matchers
+func (e *Expectation) String() string {
+	descs := make([]string, len(e.matchers))
+	for i, m := range e.matchers {
+		descs[i] = m.String()
+	}
+
+	return strings.Join(descs, " and ")
+}
+
+// matches reports whether the request satisfies every matcher. The caller must
+// hold the lock.
+func (e *Expectation) matches(r *http.Request, body []byte) bool {
+	for _, m := range e.matchers {
+		if !m.Match(r, body) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// peek returns the response for the next request meeting the expectation
+// without moving past it. The caller must hold the lock.
+func (e *Expectation) peek() Response {
+	if len(e.responses) == 0 {
+		return Response{}
+	}
+
+	idx := e.served
+	if idx >= len(e.responses) {
+		idx = len(e.responses) - 1
+	}
+
+	return e.responses[idx]
+}
@@ -0,0 +1,116 @@
+package paths
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+)
+
+// Fault represents a way for a response to fail below the level of a status
+// code, for testing how clients cope with broken servers
+type Fault int
+
+const (
+	// NoFault responds normally
+	NoFault Fault = iota
+	// DropConnection closes the connection without writing a response
+	DropConnection
+	// ResetAfterHeaders writes the response headers then resets the
+	// connection
+	ResetAfterHeaders
+	// TruncateBody writes a body shorter than its declared Content-Length
+	// then closes the connection
+	TruncateBody
+	// MalformedChunks writes a chunked body with invalid framing then closes
+	// the connection
+	MalformedChunks
+	// Stall never responds, holding the request open until the client goes
+	// away or the server is closed
+	Stall
+)
+
+var faultNames = map[Fault]string{
+	NoFault:           "no fault",
+	DropConnection:    "dropped connection",
+	ResetAfterHeaders: "reset after headers",
+	TruncateBody:      "truncated body",
+	MalformedChunks:   "malformed chunks",
+	Stall:             "stall",
+}
+
+// String returns a description of the fault
+func (f Fault) String() string {
+	if name, ok := faultNames[f]; ok {
+		return name
+	}
+
+	return "unknown fault"
+}
+
+// Inject fails the response in the way the fault describes. A stall ends by
+// aborting the response once the request's context is done. Other faults take
+// over the connection, which is only possible over HTTP/1.x; over
+// HTTP/2 the stream is aborted instead, after writing headers where the fault
+// calls for them.
+func (f Fault) Inject(w http.ResponseWriter, r *http.Request) {
+	switch f {
+	case NoFault:
+		return
+	case Stall:
+		<-r.Context().Done()
+		panic(http.ErrAbortHandler)
+	}
+
+	var conn net.Conn
+	var buf *bufio.ReadWriter
+	var err error
+
+	hj, ok := w.(http.Hijacker)
+	if ok {
+		conn, buf, err = hj.Hijack()
+	}
+
+	if !ok || err != nil {
+		f.abort(w)
+		return
+	}
+	defer conn.Close()
+
+	const head = "HTTP/1.1 200 OK\r\nContent-Type: text/plain\r\n"
+
+	switch f {
+	case ResetAfterHeaders:
+		buf.WriteString(head + "Content-Length: 64\r\n\r\n") //nolint,errcheck
+
+		if tc, ok := conn.(*net.TCPConn); ok {
+			tc.SetLinger(0) //nolint,errcheck
+		}
+
+	case TruncateBody:
+		buf.WriteString(head + "Content-Length: 64\r\n\r\ntruncated") //nolint,errcheck
+
+	case MalformedChunks:
+		buf.WriteString(head + "Transfer-Encoding: chunked\r\n\r\n5\r\nbogus\r\nnot a chunk size\r\n") //nolint,errcheck
+	}
+
+	buf.Flush() //nolint,errcheck
+}
+
+// abort fails the response without taking over the connection, by panicking
+// with http.ErrAbortHandler once any headers the fault calls for are sent
+func (f Fault) abort(w http.ResponseWriter) {
+	if f != DropConnection {
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusOK)
+
+		if f == TruncateBody {
+			w.Write([]byte("truncated")) //nolint,errcheck
+		}
+
+		if fl, ok := w.(http.Flusher); ok {
+			fl.Flush()
+		}
+	}
+
+	panic(http.ErrAbortHandler)
+}
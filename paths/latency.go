@@ -0,0 +1,119 @@
+package paths
+
+import (
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/gomicro/bogus/internal/timing"
+)
+
+// Latency decides how long to wait, drawing any randomness it needs from the
+// source provided so delays can be reproduced by seeding it
+type Latency interface {
+	Duration(rng *rand.Rand) time.Duration
+}
+
+type latencyFunc func(rng *rand.Rand) time.Duration
+
+func (f latencyFunc) Duration(rng *rand.Rand) time.Duration {
+	return f(rng)
+}
+
+// Fixed returns a latency of exactly the duration provided
+func Fixed(d time.Duration) Latency {
+	return latencyFunc(func(*rand.Rand) time.Duration {
+		return d
+	})
+}
+
+// Uniform returns a latency spread evenly between min and max, inclusive
+func Uniform(min, max time.Duration) Latency {
+	return latencyFunc(func(rng *rand.Rand) time.Duration {
+		if max <= min {
+			return min
+		}
+
+		return min + time.Duration(rng.Int63n(int64(max-min)+1))
+	})
+}
+
+// Normal returns a latency normally distributed around the mean with the
+// standard deviation provided, never falling below zero
+func Normal(mean, stddev time.Duration) Latency {
+	return latencyFunc(func(rng *rand.Rand) time.Duration {
+		d := mean + time.Duration(rng.NormFloat64()*float64(stddev))
+		if d < 0 {
+			return 0
+		}
+
+		return d
+	})
+}
+
+// Exponential returns a latency exponentially distributed with the mean
+// provided, where most delays are short and a few are much longer
+func Exponential(mean time.Duration) Latency {
+	return latencyFunc(func(rng *rand.Rand) time.Duration {
+		return time.Duration(rng.ExpFloat64() * float64(mean))
+	})
+}
+
+// pacedWriter splits what is written to it into chunks, flushing each and
+// waiting between them
+type pacedWriter struct {
+	http.ResponseWriter
+	p       *Path
+	r       *http.Request
+	size    int
+	latency Latency
+	started bool
+}
+
+func (w *pacedWriter) Write(b []byte) (int, error) {
+	written := 0
+
+	for len(b) > 0 {
+		if w.started {
+			w.p.mu.Lock()
+			delay := w.p.draw(w.latency)
+			w.p.mu.Unlock()
+
+			if !timing.Wait(w.r, delay) {
+				return written, w.r.Context().Err()
+			}
+		}
+		w.started = true
+
+		n := w.size
+		if n > len(b) {
+			n = len(b)
+		}
+
+		m, err := w.ResponseWriter.Write(b[:n])
+		written += m
+		if err != nil {
+			return written, err
+		}
+
+		if f, ok := w.ResponseWriter.(http.Flusher); ok {
+			f.Flush()
+		}
+
+		b = b[n:]
+	}
+
+	return written, nil
+}
+
+// Flush sends any buffered data to the client
+func (w *pacedWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the underlying response writer
+func (w *pacedWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,51 @@
+package paths
+
+import (
+	"context"
+	"net/http"
+)
+
+type matchKey struct{}
+
+// Match records how a path answered a request
+type Match struct {
+	// Expectation is the expectation which answered the request, if any
+	Expectation *Expectation
+
+	// Fault is the fault injected into the response, if any
+	Fault Fault
+
+	// ShortCircuit is whether a conditional request was answered with 304
+	// Not Modified or 412 Precondition Failed instead of the full response
+	ShortCircuit bool
+}
+
+// WithMatch returns a copy of the context into which HandleRequest records how
+// the request was answered
+func WithMatch(ctx context.Context, m *Match) context.Context {
+	return context.WithValue(ctx, matchKey{}, m)
+}
+
+// recordMatch stores the expectation answering the request in the request's
+// match, if it carries one
+func recordMatch(r *http.Request, e *Expectation) {
+	if m, ok := r.Context().Value(matchKey{}).(*Match); ok {
+		m.Expectation = e
+	}
+}
+
+// recordFault stores the fault injected into the response in the request's
+// match, if it carries one
+func recordFault(r *http.Request, f Fault) {
+	if m, ok := r.Context().Value(matchKey{}).(*Match); ok {
+		m.Fault = f
+	}
+}
+
+// recordShortCircuit notes in the request's match, if it carries one, that a
+// conditional request was answered without the full response
+func recordShortCircuit(r *http.Request) {
+	if m, ok := r.Context().Value(matchKey{}).(*Match); ok {
+		m.ShortCircuit = true
+	}
+}
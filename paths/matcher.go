@@ -0,0 +1,416 @@
+package paths
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Matcher decides whether a request meets a condition. The body is passed
+// separately so it may be inspected without consuming the request body.
+type Matcher interface {
+	Match(r *http.Request, body []byte) bool
+	String() string
+}
+
+type matcher struct {
+	desc  string
+	match func(r *http.Request, body []byte) bool
+}
+
+func (m matcher) Match(r *http.Request, body []byte) bool {
+	return m.match(r, body)
+}
+
+func (m matcher) String() string {
+	return m.desc
+}
+
+// MatcherFunc returns a matcher using the provided function, described by desc
+// in failure reports
+func MatcherFunc(desc string, match func(r *http.Request, body []byte) bool) Matcher {
+	return matcher{desc: desc, match: match}
+}
+
+// MethodEquals returns a matcher requiring the request to use the method
+// provided
+func MethodEquals(method string) Matcher {
+	method = strings.ToUpper(method)
+
+	return matcher{
+		desc: fmt.Sprintf("method is %v", method),
+		match: func(r *http.Request, _ []byte) bool {
+			return strings.ToUpper(r.Method) == method
+		},
+	}
+}
+
+// BodyEquals returns a matcher requiring the body to be exactly the bytes
+// provided
+func BodyEquals(expected []byte) Matcher {
+	return matcher{
+		desc: fmt.Sprintf("body equals %q", expected),
+		match: func(_ *http.Request, body []byte) bool {
+			return bytes.Equal(body, expected)
+		},
+	}
+}
+
+// BodyJSON returns a matcher requiring the body to be a JSON document
+// equivalent to the one provided, ignoring key order and whitespace. It panics
+// if the document provided is not valid JSON.
+func BodyJSON(expected []byte) Matcher {
+	var want interface{}
+	if err := json.Unmarshal(expected, &want); err != nil {
+		panic("paths: invalid JSON for body matcher: " + err.Error())
+	}
+
+	return matcher{
+		desc: fmt.Sprintf("body is JSON equivalent to %s", expected),
+		match: func(_ *http.Request, body []byte) bool {
+			var got interface{}
+			if err := json.Unmarshal(body, &got); err != nil {
+				return false
+			}
+
+			return reflect.DeepEqual(got, want)
+		},
+	}
+}
+
+// BodyJSONPath returns a matcher requiring the value found at path within a
+// JSON body to equal the value provided. Paths are dot separated field names
+// and array indexes, optionally prefixed with $, such as $.user.roles[0].
+func BodyJSONPath(path string, expected interface{}) Matcher {
+	want := normalizeJSON(expected)
+
+	return matcher{
+		desc: fmt.Sprintf("body JSON at %v equals %v", path, expected),
+		match: func(_ *http.Request, body []byte) bool {
+			got, ok := lookupJSON(body, path)
+			return ok && reflect.DeepEqual(got, want)
+		},
+	}
+}
+
+// BodyJSONPathFunc returns a matcher requiring the value found at path within
+// a JSON body to satisfy the predicate provided. See BodyJSONPath for the path
+// syntax.
+func BodyJSONPathFunc(path string, predicate func(value interface{}) bool) Matcher {
+	return matcher{
+		desc: fmt.Sprintf("body JSON at %v satisfies predicate", path),
+		match: func(_ *http.Request, body []byte) bool {
+			got, ok := lookupJSON(body, path)
+			return ok && predicate(got)
+		},
+	}
+}
+
+// BodyForm returns a matcher requiring a form encoded body to carry each of
+// the values provided. Fields not mentioned are ignored.
+func BodyForm(expected url.Values) Matcher {
+	return matcher{
+		desc: fmt.Sprintf("body form contains %v", expected.Encode()),
+		match: func(_ *http.Request, body []byte) bool {
+			got, err := url.ParseQuery(string(body))
+			if err != nil {
+				return false
+			}
+
+			for key, values := range expected {
+				if !reflect.DeepEqual(got[key], values) {
+					return false
+				}
+			}
+
+			return true
+		},
+	}
+}
+
+// BodyRegexp returns a matcher requiring the body to match the regular
+// expression provided. It panics if the expression does not compile.
+func BodyRegexp(expr string) Matcher {
+	re := regexp.MustCompile(expr)
+
+	return matcher{
+		desc: fmt.Sprintf("body matches /%v/", expr),
+		match: func(_ *http.Request, body []byte) bool {
+			return re.Match(body)
+		},
+	}
+}
+
+// HeaderEquals returns a matcher requiring the request header to have exactly
+// the value provided
+func HeaderEquals(name, value string) Matcher {
+	return matcher{
+		desc: fmt.Sprintf("header %v equals %q", name, value),
+		match: func(r *http.Request, _ []byte) bool {
+			for _, v := range r.Header.Values(name) {
+				if v == value {
+					return true
+				}
+			}
+
+			return false
+		},
+	}
+}
+
+// HeaderMatches returns a matcher requiring the request header to have a value
+// matching the regular expression provided in full, such as Bearer .* for an
+// Authorization header. It panics if the expression does not compile.
+func HeaderMatches(name, expr string) Matcher {
+	re := regexp.MustCompile("^(?:" + expr + ")$")
+
+	return matcher{
+		desc: fmt.Sprintf("header %v matches /%v/", name, expr),
+		match: func(r *http.Request, _ []byte) bool {
+			for _, v := range r.Header.Values(name) {
+				if re.MatchString(v) {
+					return true
+				}
+			}
+
+			return false
+		},
+	}
+}
+
+// HeaderPresent returns a matcher requiring the request header to be present
+// with any value
+func HeaderPresent(name string) Matcher {
+	return matcher{
+		desc: fmt.Sprintf("header %v is present", name),
+		match: func(r *http.Request, _ []byte) bool {
+			return len(r.Header.Values(name)) != 0
+		},
+	}
+}
+
+// HeaderAbsent returns a matcher requiring the request header to not be
+// present
+func HeaderAbsent(name string) Matcher {
+	return matcher{
+		desc: fmt.Sprintf("header %v is absent", name),
+		match: func(r *http.Request, _ []byte) bool {
+			return len(r.Header.Values(name)) == 0
+		},
+	}
+}
+
+// queryMatcher is a matcher on a single query param, which it names so strict
+// query matching can tell which params are expected
+type queryMatcher struct {
+	matcher
+	name string
+}
+
+func query(r *http.Request) url.Values {
+	if r.URL == nil {
+		return url.Values{}
+	}
+
+	return r.URL.Query()
+}
+
+// QueryEquals returns a matcher requiring the query param to carry exactly the
+// values provided, in the same order
+func QueryEquals(name string, values ...string) Matcher {
+	return queryMatcher{
+		name: name,
+		matcher: matcher{
+			desc: fmt.Sprintf("query %v equals %q", name, values),
+			match: func(r *http.Request, _ []byte) bool {
+				passed, ok := query(r)[name]
+				return ok && equalValues(passed, values)
+			},
+		},
+	}
+}
+
+// QueryAnyOrder returns a matcher requiring the query param to carry exactly
+// the values provided, in any order
+func QueryAnyOrder(name string, values ...string) Matcher {
+	want := sortedValues(values)
+
+	return queryMatcher{
+		name: name,
+		matcher: matcher{
+			desc: fmt.Sprintf("query %v equals %q in any order", name, values),
+			match: func(r *http.Request, _ []byte) bool {
+				passed, ok := query(r)[name]
+				return ok && equalValues(sortedValues(passed), want)
+			},
+		},
+	}
+}
+
+// QueryContains returns a matcher requiring the query param to carry at least
+// the values provided, allowing others alongside them
+func QueryContains(name string, values ...string) Matcher {
+	return queryMatcher{
+		name: name,
+		matcher: matcher{
+			desc: fmt.Sprintf("query %v contains %q", name, values),
+			match: func(r *http.Request, _ []byte) bool {
+				passed, ok := query(r)[name]
+				if !ok {
+					return false
+				}
+
+				remaining := map[string]int{}
+				for _, v := range passed {
+					remaining[v]++
+				}
+
+				for _, v := range values {
+					if remaining[v] == 0 {
+						return false
+					}
+					remaining[v]--
+				}
+
+				return true
+			},
+		},
+	}
+}
+
+// QueryMatches returns a matcher requiring every value of the query param to
+// match the regular expression provided in full. It panics if the expression
+// does not compile.
+func QueryMatches(name, expr string) Matcher {
+	re := regexp.MustCompile("^(?:" + expr + ")$")
+
+	return queryMatcher{
+		name: name,
+		matcher: matcher{
+			desc: fmt.Sprintf("query %v matches /%v/", name, expr),
+			match: func(r *http.Request, _ []byte) bool {
+				passed, ok := query(r)[name]
+				if !ok {
+					return false
+				}
+
+				for _, v := range passed {
+					if !re.MatchString(v) {
+						return false
+					}
+				}
+
+				return true
+			},
+		},
+	}
+}
+
+// QueryPresent returns a matcher requiring the query param to be present with
+// any value
+func QueryPresent(name string) Matcher {
+	return queryMatcher{
+		name: name,
+		matcher: matcher{
+			desc: fmt.Sprintf("query %v is present", name),
+			match: func(r *http.Request, _ []byte) bool {
+				_, ok := query(r)[name]
+				return ok
+			},
+		},
+	}
+}
+
+// QueryAbsent returns a matcher requiring the query param to not be present
+func QueryAbsent(name string) Matcher {
+	return queryMatcher{
+		name: name,
+		matcher: matcher{
+			desc: fmt.Sprintf("query %v is absent", name),
+			match: func(r *http.Request, _ []byte) bool {
+				_, ok := query(r)[name]
+				return !ok
+			},
+		},
+	}
+}
+
+func equalValues(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func sortedValues(values []string) []string {
+	sorted := make([]string, len(values))
+	copy(sorted, values)
+	sort.Strings(sorted)
+
+	return sorted
+}
+
+func normalizeJSON(v interface{}) interface{} {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+
+	var out interface{}
+	if err := json.Unmarshal(b, &out); err != nil {
+		return v
+	}
+
+	return out
+}
+
+func lookupJSON(body []byte, path string) (interface{}, bool) {
+	var doc interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, false
+	}
+
+	path = strings.TrimPrefix(path, "$")
+	path = strings.NewReplacer("[", ".", "]", "").Replace(path)
+
+	for _, key := range strings.Split(path, ".") {
+		if key == "" {
+			continue
+		}
+
+		switch node := doc.(type) {
+		case map[string]interface{}:
+			v, ok := node[key]
+			if !ok {
+				return nil, false
+			}
+			doc = v
+
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, false
+			}
+			doc = node[i]
+
+		default:
+			return nil, false
+		}
+	}
+
+	return doc, true
+}
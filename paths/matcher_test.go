@@ -0,0 +1,144 @@
+package paths
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+)
+
+func TestMatchers(t *testing.T) {
+	g := goblin.Goblin(t)
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("Matchers", func() {
+		r := &http.Request{Method: "POST"}
+
+		g.Describe("Body Matchers", func() {
+			g.It("should match exact bodies", func() {
+				m := BodyEquals([]byte("hello"))
+
+				Expect(m.Match(r, []byte("hello"))).To(BeTrue())
+				Expect(m.Match(r, []byte("hello "))).To(BeFalse())
+			})
+
+			g.It("should match equivalent JSON bodies", func() {
+				m := BodyJSON([]byte(`{"a": 1, "b": [true, "x"]}`))
+
+				Expect(m.Match(r, []byte(`{"b":[true,"x"],"a":1.0}`))).To(BeTrue())
+				Expect(m.Match(r, []byte(`{"a":1,"b":["x",true]}`))).To(BeFalse())
+				Expect(m.Match(r, []byte(`not json`))).To(BeFalse())
+			})
+
+			g.It("should match JSON paths", func() {
+				body := []byte(`{"user":{"name":"bob","roles":["admin","dev"],"age":42}}`)
+
+				Expect(BodyJSONPath("$.user.name", "bob").Match(r, body)).To(BeTrue())
+				Expect(BodyJSONPath("user.roles[1]", "dev").Match(r, body)).To(BeTrue())
+				Expect(BodyJSONPath("$.user.age", 42).Match(r, body)).To(BeTrue())
+				Expect(BodyJSONPath("$.user.roles", []string{"admin", "dev"}).Match(r, body)).To(BeTrue())
+				Expect(BodyJSONPath("$.user.name", "alice").Match(r, body)).To(BeFalse())
+				Expect(BodyJSONPath("$.user.missing", "bob").Match(r, body)).To(BeFalse())
+				Expect(BodyJSONPath("$.user.roles[5]", "dev").Match(r, body)).To(BeFalse())
+
+				older := BodyJSONPathFunc("$.user.age", func(v interface{}) bool {
+					age, ok := v.(float64)
+					return ok && age > 40
+				})
+				Expect(older.Match(r, body)).To(BeTrue())
+			})
+
+			g.It("should match form values", func() {
+				m := BodyForm(url.Values{"name": []string{"bob"}})
+
+				Expect(m.Match(r, []byte("name=bob&age=42"))).To(BeTrue())
+				Expect(m.Match(r, []byte("name=alice"))).To(BeFalse())
+			})
+
+			g.It("should match regular expressions", func() {
+				m := BodyRegexp(`^id=\d+$`)
+
+				Expect(m.Match(r, []byte("id=42"))).To(BeTrue())
+				Expect(m.Match(r, []byte("id=bob"))).To(BeFalse())
+			})
+
+			g.It("should describe themselves", func() {
+				Expect(BodyEquals([]byte("hi")).String()).To(Equal(`body equals "hi"`))
+				Expect(MatcherFunc("custom", nil).String()).To(Equal("custom"))
+			})
+		})
+
+		g.Describe("Method Matchers", func() {
+			g.It("should match methods regardless of case", func() {
+				Expect(MethodEquals("post").Match(r, nil)).To(BeTrue())
+				Expect(MethodEquals("GET").Match(r, nil)).To(BeFalse())
+			})
+		})
+
+		g.Describe("Header Matchers", func() {
+			r := &http.Request{
+				Header: http.Header{
+					"Authorization": []string{"Bearer abc123"},
+					"Content-Type":  []string{"application/json"},
+				},
+			}
+
+			g.It("should match header values", func() {
+				Expect(HeaderEquals("content-type", "application/json").Match(r, nil)).To(BeTrue())
+				Expect(HeaderEquals("Content-Type", "text/plain").Match(r, nil)).To(BeFalse())
+			})
+
+			g.It("should match header patterns in full", func() {
+				Expect(HeaderMatches("Authorization", "Bearer .*").Match(r, nil)).To(BeTrue())
+				Expect(HeaderMatches("Authorization", "Basic .*").Match(r, nil)).To(BeFalse())
+				Expect(HeaderMatches("Authorization", "Bearer").Match(r, nil)).To(BeFalse())
+			})
+
+			g.It("should match header presence and absence", func() {
+				Expect(HeaderPresent("Authorization").Match(r, nil)).To(BeTrue())
+				Expect(HeaderPresent("X-Missing").Match(r, nil)).To(BeFalse())
+				Expect(HeaderAbsent("X-Missing").Match(r, nil)).To(BeTrue())
+				Expect(HeaderAbsent("Authorization").Match(r, nil)).To(BeFalse())
+			})
+		})
+
+		g.Describe("Query Matchers", func() {
+			r := httptest.NewRequest("GET", "/?a=1&a=23&b=x&c=", nil)
+
+			g.It("should match exact multi-values in order", func() {
+				Expect(QueryEquals("a", "1", "23").Match(r, nil)).To(BeTrue())
+				Expect(QueryEquals("a", "123").Match(r, nil)).To(BeFalse())
+				Expect(QueryEquals("a", "23", "1").Match(r, nil)).To(BeFalse())
+				Expect(QueryEquals("missing", "").Match(r, nil)).To(BeFalse())
+			})
+
+			g.It("should match multi-values in any order", func() {
+				Expect(QueryAnyOrder("a", "23", "1").Match(r, nil)).To(BeTrue())
+				Expect(QueryAnyOrder("a", "1").Match(r, nil)).To(BeFalse())
+			})
+
+			g.It("should match subsets of values", func() {
+				Expect(QueryContains("a", "23").Match(r, nil)).To(BeTrue())
+				Expect(QueryContains("a", "23", "1").Match(r, nil)).To(BeTrue())
+				Expect(QueryContains("a", "23", "23").Match(r, nil)).To(BeFalse())
+				Expect(QueryContains("a", "2").Match(r, nil)).To(BeFalse())
+			})
+
+			g.It("should match every value against a pattern", func() {
+				Expect(QueryMatches("a", `\d+`).Match(r, nil)).To(BeTrue())
+				Expect(QueryMatches("a", `\d`).Match(r, nil)).To(BeFalse())
+				Expect(QueryMatches("b", `[a-z]`).Match(r, nil)).To(BeTrue())
+			})
+
+			g.It("should match presence and absence", func() {
+				Expect(QueryPresent("c").Match(r, nil)).To(BeTrue())
+				Expect(QueryPresent("d").Match(r, nil)).To(BeFalse())
+				Expect(QueryAbsent("d").Match(r, nil)).To(BeTrue())
+				Expect(QueryAbsent("c").Match(r, nil)).To(BeFalse())
+			})
+		})
+	})
+}
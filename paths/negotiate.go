@@ -0,0 +1,158 @@
+package paths
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gomicro/bogus/internal/accept"
+)
+
+// Representation represents one form of a path's response, chosen between by
+// content negotiation. Language and Encoding are optional; an encoding means
+// the payload is already encoded with it, such as gzip.
+type Representation struct {
+	MediaType string
+	Language  string
+	Encoding  string
+	Payload   []byte
+}
+
+// AddRepresentations adds forms of the response to choose between based on
+// the request's Accept, Accept-Language, and Accept-Encoding headers, and
+// returns the path for additional configuration. They take the place of the
+// path's payload and queued responses. The form the client prefers most is
+// replied with, earlier forms winning ties, and requests accepting none of
+// them are answered with 406 Not Acceptable.
+func (p *Path) AddRepresentations(reps ...Representation) *Path {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.representations = append(p.representations, reps...)
+	return p
+}
+
+// negotiate picks the representation the request prefers most, reporting
+// false with a 406 response if it accepts none of them. The caller must hold
+// the lock.
+func (p *Path) negotiate(r *http.Request) (Response, bool) {
+	types := accept.Parse(r.Header.Values("Accept"))
+	languages := accept.Parse(r.Header.Values("Accept-Language"))
+	encodings := accept.Parse(r.Header.Values("Accept-Encoding"))
+
+	headers := map[string]string{"Vary": p.vary()}
+
+	best, bestQ := -1, 0.0
+	for i, rep := range p.representations {
+		q := mediaQuality(types, rep.MediaType) *
+			languageQuality(languages, rep.Language) *
+			accept.EncodingQuality(encodings, rep.Encoding)
+
+		if q > bestQ {
+			best, bestQ = i, q
+		}
+	}
+
+	if best < 0 {
+		return Response{
+			Status:  http.StatusNotAcceptable,
+			Headers: headers,
+			Payload: []byte(""),
+		}, false
+	}
+
+	rep := p.representations[best]
+	headers["Content-Type"] = rep.MediaType
+	if rep.Language != "" {
+		headers["Content-Language"] = rep.Language
+	}
+	if rep.Encoding != "" {
+		headers["Content-Encoding"] = rep.Encoding
+	}
+
+	return Response{
+		Status:  p.status,
+		Headers: headers,
+		Payload: rep.Payload,
+	}, true
+}
+
+// vary lists the request headers the choice of representation depends on.
+// Accept always takes part, while Accept-Language and Accept-Encoding only do
+// once a representation sets a language or encoding. The caller must hold the
+// lock.
+func (p *Path) vary() string {
+	vary := []string{"Accept"}
+
+	languages, encodings := false, false
+	for _, rep := range p.representations {
+		languages = languages || rep.Language != ""
+		encodings = encodings || rep.Encoding != ""
+	}
+
+	if languages {
+		vary = append(vary, "Accept-Language")
+	}
+	if encodings {
+		vary = append(vary, "Accept-Encoding")
+	}
+
+	return strings.Join(vary, ", ")
+}
+
+// mediaQuality returns the quality the Accept ranges give the media type,
+// using the most specific range matching it
+func mediaQuality(ranges []accept.Range, mediaType string) float64 {
+	if ranges == nil {
+		return 1
+	}
+
+	mediaType = strings.ToLower(strings.TrimSpace(strings.Split(mediaType, ";")[0]))
+	major := strings.Split(mediaType, "/")[0]
+
+	q, specificity := 0.0, -1
+	for _, ar := range ranges {
+		s := -1
+		switch ar.Value {
+		case mediaType:
+			s = 2
+		case major + "/*":
+			s = 1
+		case "*/*":
+			s = 0
+		}
+
+		if s > specificity {
+			q, specificity = ar.Q, s
+		}
+	}
+
+	return q
+}
+
+// languageQuality returns the quality the Accept-Language ranges give the
+// language, using the longest range matching it by prefix. Representations
+// without a language suit any request.
+func languageQuality(ranges []accept.Range, language string) float64 {
+	if ranges == nil || language == "" {
+		return 1
+	}
+
+	language = strings.ToLower(language)
+
+	q, specificity := 0.0, -1
+	for _, ar := range ranges {
+		s := -1
+		switch {
+		case ar.Value == "*":
+			s = 0
+		case ar.Value == language || strings.HasPrefix(language, ar.Value+"-"):
+			s = len(ar.Value)
+		}
+
+		if s > specificity {
+			q, specificity = ar.Q, s
+		}
+	}
+
+	return q
+}
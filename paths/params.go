@@ -0,0 +1,26 @@
+package paths
+
+import (
+	"context"
+	"net/http"
+)
+
+type paramsKey struct{}
+
+// WithParams returns a copy of the context carrying the route parameters
+// captured for a request
+func WithParams(ctx context.Context, params map[string]string) context.Context {
+	return context.WithValue(ctx, paramsKey{}, params)
+}
+
+// Params returns the route parameters captured for the request, such as the
+// id in a route of /users/{id}. It returns an empty map when none were
+// captured.
+func Params(r *http.Request) map[string]string {
+	params, ok := r.Context().Value(paramsKey{}).(map[string]string)
+	if !ok {
+		return map[string]string{}
+	}
+
+	return params
+}
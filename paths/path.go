@@ -1,19 +1,71 @@
 package paths
 
 import (
+	"bytes"
+	"io/ioutil"
+	"math/rand"
 	"net/http"
 	"net/url"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gomicro/bogus/internal/timing"
 )
 
-// Path represents an endpoint added to a bogus server and how it should respond
+// Path represents an endpoint added to a bogus server and how it should
+// respond. It is safe for concurrent use; it may be configured while requests
+// are being handled.
 type Path struct {
-	Hits    int
+	hits int64
+
+	mu      sync.RWMutex
 	headers map[string]string
 	payload []byte
 	status  int
 	methods []string
 	params  url.Values
+
+	responses  []Response
+	exhaustion Exhaustion
+	served     int
+
+	responder func(*http.Request) Response
+	handler   http.HandlerFunc
+	stream    *stream
+
+	methodResponses map[string]Response
+
+	expectations []*Expectation
+	unmatched    *Response
+
+	checks      []check
+	strictQuery bool
+
+	bounded bool
+	minHits int
+	maxHits int
+
+	rng          *rand.Rand
+	latency      Latency
+	chunkSize    int
+	chunkLatency Latency
+
+	fault            Fault
+	faultProbability float64
+
+	representations []Representation
+
+	conditions conditions
+}
+
+// check is a matcher every request to a path must meet, along with the status
+// to reply with when it is not met
+type check struct {
+	matcher Matcher
+	status  int
 }
 
 // New returns a newly instantiated path object with everything initialized as
@@ -21,12 +73,16 @@ type Path struct {
 func New() *Path {
 	return &Path{
 		status: http.StatusOK,
+		rng:    rand.New(rand.NewSource(time.Now().UnixNano())),
 	}
 }
 
 // SetHeaders sets the response headers for the path and returns the path for
 // additional configuration
 func (p *Path) SetHeaders(headers map[string]string) *Path {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
 	p.headers = headers
 	return p
 }
@@ -34,6 +90,9 @@ func (p *Path) SetHeaders(headers map[string]string) *Path {
 // SetPayload sets the response payload for the path and returns the path for
 // additional configuration
 func (p *Path) SetPayload(payload []byte) *Path {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
 	p.payload = payload
 	return p
 }
@@ -41,6 +100,9 @@ func (p *Path) SetPayload(payload []byte) *Path {
 // SetStatus sets the http status for the path and returns the path for
 // additional configuration
 func (p *Path) SetStatus(status int) *Path {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
 	p.status = status
 	return p
 }
@@ -51,54 +113,443 @@ func (p *Path) SetMethods(methods ...string) *Path {
 		methods[i] = strings.ToUpper(m)
 	}
 
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
 	p.methods = methods
 	return p
 }
 
-// SetParams sets the expected params and returns path
+// RequireHeaders adds matchers every request to the path must meet, such as
+// HeaderMatches("Authorization", "Bearer .*"), and returns the path for
+// additional configuration. Requests failing any of them are answered with the
+// status provided, or forbidden if it is zero.
+func (p *Path) RequireHeaders(status int, matchers ...Matcher) *Path {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, m := range matchers {
+		p.checks = append(p.checks, check{matcher: m, status: status})
+	}
+
+	return p
+}
+
+// SetMethodResponse sets the response for a single method and returns the path
+// for additional configuration. Once any method response is set, requests
+// using a method that has neither a response of its own nor was allowed by
+// SetMethods are answered with 405 Method Not Allowed and an Allow header.
+func (p *Path) SetMethodResponse(method string, resp Response) *Path {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.methodResponses == nil {
+		p.methodResponses = map[string]Response{}
+	}
+
+	p.methodResponses[strings.ToUpper(method)] = resp
+	return p
+}
+
+// SetParams sets the expected params and returns path. Each param must carry
+// exactly the values provided, in the same order.
 func (p *Path) SetParams(params url.Values) *Path {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
 	p.params = params
 	return p
 }
 
+// MatchQuery adds query matchers, such as QueryAnyOrder or QueryAbsent, every
+// request to the path must meet and returns the path for additional
+// configuration. Requests failing any of them are answered with a forbidden
+// status.
+func (p *Path) MatchQuery(matchers ...Matcher) *Path {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, m := range matchers {
+		p.checks = append(p.checks, check{matcher: m})
+	}
+
+	return p
+}
+
+// SetStrictQuery sets whether requests carrying query params not named by
+// SetParams or a query matcher on the path or one of its expectations are
+// refused with a forbidden status, and returns the path for additional
+// configuration
+func (p *Path) SetStrictQuery(strict bool) *Path {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.strictQuery = strict
+	return p
+}
+
+// AddResponses queues responses to be replied with in order, one per
+// successful request, and returns the path for additional configuration.
+// Headers set on the path are sent with every response, and headers on a
+// queued response are added on top. What happens once the queue is used up is
+// controlled by SetExhaustion.
+func (p *Path) AddResponses(responses ...Response) *Path {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.responses = append(p.responses, responses...)
+	return p
+}
+
+// SetExhaustion sets what the path replies with once its queued responses have
+// been used up and returns the path for additional configuration. The default
+// is to repeat the last queued response.
+func (p *Path) SetExhaustion(exhaustion Exhaustion) *Path {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.exhaustion = exhaustion
+	return p
+}
+
+// SetResponder sets a function to build the response for each request the path
+// accepts and returns the path for additional configuration. Method and param
+// checks still apply, and hits are still counted, before the function is
+// called. Route params are available to it through Params.
+func (p *Path) SetResponder(responder func(*http.Request) Response) *Path {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.responder = responder
+	return p
+}
+
+// SetHandlerFunc sets a handler to write the response for each request the
+// path accepts and returns the path for additional configuration. Method and
+// param checks still apply, and hits are still counted, before the handler is
+// called. It takes precedence over a responder.
+func (p *Path) SetHandlerFunc(handler http.HandlerFunc) *Path {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.handler = handler
+	return p
+}
+
+// Expect adds an expectation to the path and returns it for configuring its
+// response. Once a path has expectations, each accepted request is answered
+// by the first expectation whose matchers are all met, in the order they were
+// added. Requests meeting none are answered with the unmatched response.
+func (p *Path) Expect(matchers ...Matcher) *Expectation {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	e := &Expectation{
+		mu:       &p.mu,
+		matchers: matchers,
+	}
+	p.expectations = append(p.expectations, e)
+
+	return e
+}
+
+// SetUnmatched sets the response for requests meeting none of the path's
+// expectations and returns the path for additional configuration. The default
+// is a forbidden status.
+func (p *Path) SetUnmatched(resp Response) *Path {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.unmatched = &resp
+	return p
+}
+
+// SetDelay sets how long the path waits before responding and returns the path
+// for additional configuration. The wait is cut short if the client goes away.
+func (p *Path) SetDelay(delay time.Duration) *Path {
+	return p.SetLatency(Fixed(delay))
+}
+
+// SetLatency sets how long the path waits before writing the response headers,
+// such as Uniform(10*time.Millisecond, 50*time.Millisecond), and returns the
+// path for additional configuration. The wait is cut short if the client goes
+// away.
+func (p *Path) SetLatency(latency Latency) *Path {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.latency = latency
+	return p
+}
+
+// SetChunkLatency splits response bodies into chunks of the size provided,
+// flushing each to the client and waiting between them, and returns the path
+// for additional configuration
+func (p *Path) SetChunkLatency(size int, latency Latency) *Path {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.chunkSize = size
+	p.chunkLatency = latency
+	return p
+}
+
+// SetFault sets a fault to inject into responses with the probability
+// provided, from 0 to 1, and returns the path for additional configuration.
+// Faulted requests still count as hits.
+func (p *Path) SetFault(fault Fault, probability float64) *Path {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.fault = fault
+	p.faultProbability = probability
+	return p
+}
+
+// SetSeed seeds the source random latencies and faults are drawn from, so
+// they are the same on every run, and returns the path for additional
+// configuration
+func (p *Path) SetSeed(seed int64) *Path {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.rng = rand.New(rand.NewSource(seed))
+	return p
+}
+
+// Hits returns the number of requests the path has successfully responded to
+func (p *Path) Hits() int {
+	return int(atomic.LoadInt64(&p.hits))
+}
+
+// ResetHits clears the hit counts of the path and its expectations and starts
+// their queued responses over from the first
+func (p *Path) ResetHits() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	atomic.StoreInt64(&p.hits, 0)
+	p.served = 0
+
+	for _, e := range p.expectations {
+		atomic.StoreInt64(&e.hits, 0)
+		e.served = 0
+	}
+}
+
 // HandleRequest writes to the response writer based how it is configured to
 // handle the request.  If it is not configured to handle the requet it will
 // return a forbidden status.
 func (p *Path) HandleRequest(w http.ResponseWriter, r *http.Request) {
-	payload := []byte("")
-	status := http.StatusForbidden
+	var body []byte
+	if r.Body != nil {
+		body, _ = ioutil.ReadAll(r.Body)
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	p.mu.Lock()
+	headers := p.headers
+	handler := p.handler
+	responder := p.responder
+	stream := p.stream
+	status := p.status
+	delay := p.draw(p.latency)
+	resp, dynamic, serve := p.respond(r, body)
+	delay += resp.Delay
+	fault := p.drawFault()
+	chunkSize, chunkLatency := p.chunkSize, p.chunkLatency
+
+	// preconditions are decided before the response is served, so a request
+	// answered without it does not use up a queued response or count as a hit
+	cond := p.conditions
+	validated := cond.enabled(dynamic) && (dynamic || resp.Status == 0 || resp.Status/100 == 2)
+	etag, precondition := "", 0
+	if validated {
+		etag = cond.etag
+		if cond.generate && !dynamic {
+			etag = generateETag(resp.Payload)
+		}
+
+		precondition = cond.evaluate(r, etag)
+	}
+
+	if precondition == 0 {
+		serve()
+	}
+	p.mu.Unlock()
+
+	if !timing.Wait(r, delay) {
+		return
+	}
+
+	if fault != NoFault {
+		recordFault(r, fault)
+		fault.Inject(w, r)
+		return
+	}
+
+	if chunkSize > 0 {
+		w = &pacedWriter{ResponseWriter: w, p: p, r: r, size: chunkSize, latency: chunkLatency}
+	}
 
-	for header, value := range p.headers {
+	for header, value := range headers {
 		w.Header().Set(header, value)
 	}
 
-	if r.URL != nil {
-		vars := r.URL.Query()
-		for param, value := range p.params {
-			passed, ok := vars[param]
-			if !ok {
-				w.WriteHeader(status)
-				w.Write(payload) //nolint,errcheck
-				return
+	if validated {
+		cond.validators(w, etag)
+	}
+
+	if precondition != 0 {
+		resp.setHeaders(w)
+
+		recordShortCircuit(r)
+		w.WriteHeader(precondition)
+		return
+	}
+
+	switch {
+	case dynamic && handler != nil:
+		handler(w, r)
+	case dynamic && responder != nil:
+		responder(r).write(w)
+	case dynamic && stream != nil:
+		stream.write(w, r, status)
+	default:
+		resp.write(w)
+	}
+}
+
+// respond decides on the response for a request and reports whether it should
+// instead be left to the path's handler, responder, or stream. The function
+// returned counts the hit and moves past the response once it is actually
+// served. The caller must hold the lock.
+func (p *Path) respond(r *http.Request, body []byte) (Response, bool, func()) {
+	serve := func() {}
+
+	forbidden := Response{
+		Status:  http.StatusForbidden,
+		Payload: []byte(""),
+	}
+
+	vars := query(r)
+	for param, value := range p.params {
+		passed, ok := vars[param]
+		if !ok {
+			return forbidden, false, serve
+		}
+
+		if !equalValues(passed, value) {
+			return forbidden, false, serve
+		}
+	}
+
+	if p.strictQuery {
+		for param := range vars {
+			if !p.expectsParam(param) {
+				return forbidden, false, serve
 			}
+		}
+	}
 
-			if strings.Join(passed, "") != strings.Join(value, "") {
-				w.WriteHeader(status)
-				w.Write(payload) //nolint,errcheck
-				return
+	for _, c := range p.checks {
+		if !c.matcher.Match(r, body) {
+			if c.status == 0 {
+				return forbidden, false, serve
 			}
+
+			return Response{Status: c.status, Payload: []byte("")}, false, serve
 		}
 	}
 
-	if p.hasMethod(r.Method) {
-		p.Hits++
-		w.WriteHeader(p.status)
-		w.Write(p.payload) //nolint,errcheck
-		return
+	methodResp, hasMethodResp := p.methodResponses[strings.ToUpper(r.Method)]
+
+	if !hasMethodResp && !p.hasMethod(r.Method) {
+		if len(p.methodResponses) != 0 {
+			return Response{
+				Status:  http.StatusMethodNotAllowed,
+				Headers: map[string]string{"Allow": strings.Join(p.allowed(), ", ")},
+				Payload: []byte(""),
+			}, false, serve
+		}
+
+		return forbidden, false, serve
+	}
+
+	if len(p.expectations) != 0 {
+		for _, e := range p.expectations {
+			if e.matches(r, body) {
+				recordMatch(r, e)
+				return e.peek(), false, func() {
+					atomic.AddInt64(&p.hits, 1)
+					atomic.AddInt64(&e.hits, 1)
+					e.served++
+				}
+			}
+		}
+
+		if p.unmatched != nil {
+			return *p.unmatched, false, serve
+		}
+
+		return forbidden, false, serve
 	}
 
-	w.WriteHeader(status)
-	w.Write(payload) //nolint,errcheck
+	dynamic := p.handler != nil || p.responder != nil || p.stream != nil
+
+	if !hasMethodResp && !dynamic && len(p.representations) != 0 {
+		resp, ok := p.negotiate(r)
+		if ok {
+			serve = p.hit
+		}
+
+		return resp, false, serve
+	}
+
+	if hasMethodResp {
+		return methodResp, false, p.hit
+	}
+
+	if dynamic {
+		return Response{}, true, p.hit
+	}
+
+	return p.peek(), false, func() {
+		p.hit()
+		p.served++
+	}
+}
+
+// hit counts a request the path has responded to
+func (p *Path) hit() {
+	atomic.AddInt64(&p.hits, 1)
+}
+
+// peek returns the next response in the queue, or the path's own response if
+// nothing is queued, without moving past it. The caller must hold the lock.
+func (p *Path) peek() Response {
+	fallback := Response{
+		Status:  p.status,
+		Payload: p.payload,
+	}
+
+	if len(p.responses) == 0 {
+		return fallback
+	}
+
+	idx := p.served
+	if idx < len(p.responses) {
+		return p.responses[idx]
+	}
+
+	switch p.exhaustion {
+	case Cycle:
+		return p.responses[idx%len(p.responses)]
+	case Fallback:
+		return fallback
+	default:
+		return p.responses[len(p.responses)-1]
+	}
 }
 
 func (p *Path) hasMethod(method string) bool {
@@ -114,3 +565,72 @@ func (p *Path) hasMethod(method string) bool {
 
 	return false
 }
+
+// allowed returns the sorted methods the path will respond to. The caller
+// must hold the lock.
+func (p *Path) allowed() []string {
+	seen := map[string]bool{}
+	methods := []string{}
+
+	for _, m := range p.methods {
+		if !seen[m] {
+			seen[m] = true
+			methods = append(methods, m)
+		}
+	}
+
+	for m := range p.methodResponses {
+		if !seen[m] {
+			seen[m] = true
+			methods = append(methods, m)
+		}
+	}
+
+	sort.Strings(methods)
+	return methods
+}
+
+// expectsParam reports whether the query param is named by the path's params
+// or by query matchers on the path or its expectations. The caller must hold
+// the lock.
+func (p *Path) expectsParam(name string) bool {
+	if _, ok := p.params[name]; ok {
+		return true
+	}
+
+	for _, c := range p.checks {
+		if qm, ok := c.matcher.(queryMatcher); ok && qm.name == name {
+			return true
+		}
+	}
+
+	for _, e := range p.expectations {
+		for _, m := range e.matchers {
+			if qm, ok := m.(queryMatcher); ok && qm.name == name {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// draw returns a delay from the latency provided, or none if it is nil. The
+// caller must hold the lock.
+func (p *Path) draw(latency Latency) time.Duration {
+	if latency == nil {
+		return 0
+	}
+
+	return latency.Duration(p.rng)
+}
+
+// drawFault returns the path's fault if it is to be injected into this
+// response. The caller must hold the lock.
+func (p *Path) drawFault() Fault {
+	if p.fault == NoFault || p.rng.Float64() >= p.faultProbability {
+		return NoFault
+	}
+
+	return p.fault
+}
@@ -1,8 +1,18 @@
 package paths
 
 import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/franela/goblin"
 	"github.com/gomicro/penname"
@@ -126,5 +136,922 @@ func TestBogus(t *testing.T) {
 				Expect(string(w.WrittenHeaders)).To(Equal("Header: 200"))
 			})
 		})
+
+		g.Describe("Concurrency", func() {
+			g.It("should count hits across concurrent requests", func() {
+				p := New().
+					SetMethods("GET")
+
+				var wg sync.WaitGroup
+				for i := 0; i < 50; i++ {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+
+						p.SetStatus(http.StatusOK)
+						p.HandleRequest(httptest.NewRecorder(), &http.Request{Method: "GET"})
+					}()
+				}
+				wg.Wait()
+
+				Expect(p.Hits()).To(Equal(50))
+			})
+		})
+
+		g.Describe("Params", func() {
+			g.It("should return params stored on the request context", func() {
+				r := &http.Request{}
+				Expect(Params(r)).To(BeEmpty())
+
+				r = r.WithContext(WithParams(context.Background(), map[string]string{"id": "42"}))
+				Expect(Params(r)).To(Equal(map[string]string{"id": "42"}))
+			})
+		})
+
+		g.Describe("Response Sequences", func() {
+			statuses := func(p *Path, n int) []int {
+				codes := []int{}
+				for i := 0; i < n; i++ {
+					w := httptest.NewRecorder()
+					p.HandleRequest(w, &http.Request{Method: "GET"})
+					codes = append(codes, w.Code)
+				}
+				return codes
+			}
+
+			g.It("should reply with queued responses in order and repeat the last", func() {
+				p := New().
+					SetMethods("GET").
+					AddResponses(
+						Response{Status: http.StatusServiceUnavailable},
+						Response{Status: http.StatusServiceUnavailable},
+						Response{Status: http.StatusOK, Payload: []byte("done")},
+					)
+
+				Expect(statuses(p, 4)).To(Equal([]int{503, 503, 200, 200}))
+				Expect(p.Hits()).To(Equal(4))
+			})
+
+			g.It("should cycle through queued responses", func() {
+				p := New().
+					SetMethods("GET").
+					AddResponses(
+						Response{Status: http.StatusInternalServerError},
+						Response{Status: http.StatusOK},
+					).
+					SetExhaustion(Cycle)
+
+				Expect(statuses(p, 5)).To(Equal([]int{500, 200, 500, 200, 500}))
+			})
+
+			g.It("should fall back to the path response", func() {
+				p := New().
+					SetMethods("GET").
+					SetStatus(http.StatusTeapot).
+					AddResponses(Response{Status: http.StatusInternalServerError}).
+					SetExhaustion(Fallback)
+
+				Expect(statuses(p, 3)).To(Equal([]int{500, 418, 418}))
+			})
+
+			g.It("should layer queued headers over path headers", func() {
+				p := New().
+					SetMethods("GET").
+					SetHeaders(map[string]string{"Content-Type": "text/plain", "X-Path": "yes"}).
+					AddResponses(Response{Headers: map[string]string{"Content-Type": "application/json"}})
+
+				w := httptest.NewRecorder()
+				p.HandleRequest(w, &http.Request{Method: "GET"})
+
+				Expect(w.Code).To(Equal(http.StatusOK))
+				Expect(w.Header().Get("Content-Type")).To(Equal("application/json"))
+				Expect(w.Header().Get("X-Path")).To(Equal("yes"))
+			})
+
+			g.It("should not advance the queue on forbidden requests", func() {
+				p := New().
+					SetMethods("GET").
+					AddResponses(
+						Response{Status: http.StatusServiceUnavailable},
+						Response{Status: http.StatusOK},
+					)
+
+				w := httptest.NewRecorder()
+				p.HandleRequest(w, &http.Request{Method: "POST"})
+				Expect(w.Code).To(Equal(http.StatusForbidden))
+
+				Expect(statuses(p, 2)).To(Equal([]int{503, 200}))
+			})
+		})
+
+		g.Describe("Dynamic Responses", func() {
+			g.It("should build responses with a responder", func() {
+				p := New().
+					SetMethods("GET").
+					SetResponder(func(r *http.Request) Response {
+						return Response{
+							Status:  http.StatusCreated,
+							Payload: []byte("user " + Params(r)["id"]),
+						}
+					})
+
+				r := &http.Request{Method: "GET"}
+				r = r.WithContext(WithParams(context.Background(), map[string]string{"id": "42"}))
+
+				w := httptest.NewRecorder()
+				p.HandleRequest(w, r)
+
+				Expect(w.Code).To(Equal(http.StatusCreated))
+				Expect(w.Body.String()).To(Equal("user 42"))
+				Expect(p.Hits()).To(Equal(1))
+			})
+
+			g.It("should let responders depend on earlier hits", func() {
+				var p *Path
+				p = New().
+					SetMethods("GET").
+					SetResponder(func(r *http.Request) Response {
+						return Response{Payload: []byte(fmt.Sprintf("hit %v", p.Hits()))}
+					})
+
+				w := httptest.NewRecorder()
+				p.HandleRequest(w, &http.Request{Method: "GET"})
+				Expect(w.Body.String()).To(Equal("hit 1"))
+
+				w = httptest.NewRecorder()
+				p.HandleRequest(w, &http.Request{Method: "GET"})
+				Expect(w.Body.String()).To(Equal("hit 2"))
+			})
+
+			g.It("should write responses with a handler func", func() {
+				p := New().
+					SetMethods("POST").
+					SetHeaders(map[string]string{"X-Path": "yes"}).
+					SetHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+						w.WriteHeader(http.StatusAccepted)
+						w.Write([]byte(r.Method)) //nolint,errcheck
+					})
+
+				w := httptest.NewRecorder()
+				p.HandleRequest(w, &http.Request{Method: "POST"})
+
+				Expect(w.Code).To(Equal(http.StatusAccepted))
+				Expect(w.Body.String()).To(Equal("POST"))
+				Expect(w.Header().Get("X-Path")).To(Equal("yes"))
+				Expect(p.Hits()).To(Equal(1))
+			})
+
+			g.It("should not call custom handlers for rejected requests", func() {
+				called := false
+				p := New().
+					SetMethods("GET").
+					SetHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+						called = true
+					})
+
+				w := httptest.NewRecorder()
+				p.HandleRequest(w, &http.Request{Method: "DELETE"})
+
+				Expect(w.Code).To(Equal(http.StatusForbidden))
+				Expect(called).To(BeFalse())
+				Expect(p.Hits()).To(Equal(0))
+			})
+		})
+
+		g.Describe("Method Responses", func() {
+			g.It("should reply per method", func() {
+				p := New().
+					SetMethodResponse("get", Response{Payload: []byte("resource")}).
+					SetMethodResponse("DELETE", Response{Status: http.StatusNoContent}).
+					SetMethodResponse("POST", Response{
+						Status:  http.StatusCreated,
+						Headers: map[string]string{"Location": "/users/42"},
+					})
+
+				w := httptest.NewRecorder()
+				p.HandleRequest(w, &http.Request{Method: "GET"})
+				Expect(w.Code).To(Equal(http.StatusOK))
+				Expect(w.Body.String()).To(Equal("resource"))
+
+				w = httptest.NewRecorder()
+				p.HandleRequest(w, &http.Request{Method: "DELETE"})
+				Expect(w.Code).To(Equal(http.StatusNoContent))
+
+				w = httptest.NewRecorder()
+				p.HandleRequest(w, &http.Request{Method: "POST"})
+				Expect(w.Code).To(Equal(http.StatusCreated))
+				Expect(w.Header().Get("Location")).To(Equal("/users/42"))
+
+				Expect(p.Hits()).To(Equal(3))
+			})
+
+			g.It("should answer unconfigured methods with 405 and an allow header", func() {
+				p := New().
+					SetMethods("HEAD").
+					SetMethodResponse("GET", Response{}).
+					SetMethodResponse("DELETE", Response{Status: http.StatusNoContent})
+
+				w := httptest.NewRecorder()
+				p.HandleRequest(w, &http.Request{Method: "PUT"})
+
+				Expect(w.Code).To(Equal(http.StatusMethodNotAllowed))
+				Expect(w.Header().Get("Allow")).To(Equal("DELETE, GET, HEAD"))
+				Expect(p.Hits()).To(Equal(0))
+
+				w = httptest.NewRecorder()
+				p.HandleRequest(w, &http.Request{Method: "HEAD"})
+				Expect(w.Code).To(Equal(http.StatusOK))
+			})
+
+			g.It("should prefer method responses over a handler", func() {
+				p := New().
+					SetMethods("GET", "DELETE").
+					SetMethodResponse("DELETE", Response{Status: http.StatusNoContent}).
+					SetHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+						w.WriteHeader(http.StatusTeapot)
+					})
+
+				w := httptest.NewRecorder()
+				p.HandleRequest(w, &http.Request{Method: "DELETE"})
+				Expect(w.Code).To(Equal(http.StatusNoContent))
+
+				w = httptest.NewRecorder()
+				p.HandleRequest(w, &http.Request{Method: "GET"})
+				Expect(w.Code).To(Equal(http.StatusTeapot))
+			})
+		})
+
+		g.Describe("Expectations", func() {
+			post := func(body string) *http.Request {
+				r := httptest.NewRequest("POST", "/", strings.NewReader(body))
+				return r
+			}
+
+			g.It("should answer with the first matching expectation", func() {
+				p := New().
+					SetMethods("POST")
+
+				created := p.Expect(BodyJSONPath("$.name", "bob")).
+					Respond(Response{Status: http.StatusCreated, Payload: []byte("bob")})
+				conflict := p.Expect(BodyRegexp("name")).
+					Respond(Response{Status: http.StatusConflict})
+
+				w := httptest.NewRecorder()
+				p.HandleRequest(w, post(`{"name":"bob"}`))
+				Expect(w.Code).To(Equal(http.StatusCreated))
+				Expect(w.Body.String()).To(Equal("bob"))
+
+				w = httptest.NewRecorder()
+				p.HandleRequest(w, post(`{"name":"alice"}`))
+				Expect(w.Code).To(Equal(http.StatusConflict))
+
+				Expect(created.Hits()).To(Equal(1))
+				Expect(conflict.Hits()).To(Equal(1))
+				Expect(p.Hits()).To(Equal(2))
+			})
+
+			g.It("should answer with the unmatched response", func() {
+				p := New().
+					SetMethods("POST")
+				p.Expect(BodyEquals([]byte("ping"))).
+					Respond(Response{Payload: []byte("pong")})
+
+				w := httptest.NewRecorder()
+				p.HandleRequest(w, post("nope"))
+				Expect(w.Code).To(Equal(http.StatusForbidden))
+
+				p.SetUnmatched(Response{Status: http.StatusBadRequest, Payload: []byte("no match")})
+
+				w = httptest.NewRecorder()
+				p.HandleRequest(w, post("nope"))
+				Expect(w.Code).To(Equal(http.StatusBadRequest))
+				Expect(w.Body.String()).To(Equal("no match"))
+				Expect(p.Hits()).To(Equal(0))
+			})
+
+			g.It("should reply with expectation responses in turn", func() {
+				p := New().
+					SetMethods("POST")
+				p.Expect(BodyEquals([]byte("retry"))).
+					Respond(
+						Response{Status: http.StatusServiceUnavailable},
+						Response{Status: http.StatusOK},
+					)
+
+				codes := []int{}
+				for i := 0; i < 3; i++ {
+					w := httptest.NewRecorder()
+					p.HandleRequest(w, post("retry"))
+					codes = append(codes, w.Code)
+				}
+
+				Expect(codes).To(Equal([]int{503, 200, 200}))
+			})
+
+			g.It("should leave the body readable for handlers", func() {
+				p := New().
+					SetMethods("POST").
+					SetResponder(func(r *http.Request) Response {
+						b, _ := ioutil.ReadAll(r.Body)
+						return Response{Payload: b}
+					})
+
+				w := httptest.NewRecorder()
+				p.HandleRequest(w, post("echo"))
+				Expect(w.Body.String()).To(Equal("echo"))
+			})
+		})
+
+		g.Describe("Required Headers", func() {
+			g.It("should answer failed header checks with the configured status", func() {
+				p := New().
+					SetMethods("POST").
+					RequireHeaders(http.StatusUnauthorized, HeaderMatches("Authorization", "Bearer .*")).
+					RequireHeaders(http.StatusUnsupportedMediaType, HeaderEquals("Content-Type", "application/json")).
+					RequireHeaders(0, HeaderAbsent("X-Forbidden"))
+
+				r := httptest.NewRequest("POST", "/", nil)
+
+				w := httptest.NewRecorder()
+				p.HandleRequest(w, r)
+				Expect(w.Code).To(Equal(http.StatusUnauthorized))
+
+				r.Header.Set("Authorization", "Bearer abc")
+				w = httptest.NewRecorder()
+				p.HandleRequest(w, r)
+				Expect(w.Code).To(Equal(http.StatusUnsupportedMediaType))
+
+				r.Header.Set("Content-Type", "application/json")
+				r.Header.Set("X-Forbidden", "yes")
+				w = httptest.NewRecorder()
+				p.HandleRequest(w, r)
+				Expect(w.Code).To(Equal(http.StatusForbidden))
+
+				r.Header.Del("X-Forbidden")
+				w = httptest.NewRecorder()
+				p.HandleRequest(w, r)
+				Expect(w.Code).To(Equal(http.StatusOK))
+				Expect(p.Hits()).To(Equal(1))
+			})
+
+			g.It("should allow header matchers on expectations", func() {
+				p := New().
+					SetMethods("GET")
+				p.Expect(HeaderEquals("Accept", "application/json")).
+					Respond(Response{Payload: []byte("json")})
+				p.Expect(HeaderPresent("Accept")).
+					Respond(Response{Payload: []byte("other")})
+
+				r := httptest.NewRequest("GET", "/", nil)
+				r.Header.Set("Accept", "application/json")
+				w := httptest.NewRecorder()
+				p.HandleRequest(w, r)
+				Expect(w.Body.String()).To(Equal("json"))
+
+				r.Header.Set("Accept", "text/plain")
+				w = httptest.NewRecorder()
+				p.HandleRequest(w, r)
+				Expect(w.Body.String()).To(Equal("other"))
+			})
+		})
+
+		g.Describe("Query Matching", func() {
+			status := func(p *Path, target string) int {
+				w := httptest.NewRecorder()
+				p.HandleRequest(w, httptest.NewRequest("GET", target, nil))
+				return w.Code
+			}
+
+			g.It("should compare params value by value", func() {
+				p := New().
+					SetMethods("GET").
+					SetParams(url.Values{"a": []string{"123"}})
+
+				Expect(status(p, "/?a=123")).To(Equal(http.StatusOK))
+				Expect(status(p, "/?a=1&a=23")).To(Equal(http.StatusForbidden))
+			})
+
+			g.It("should apply query matchers", func() {
+				p := New().
+					SetMethods("GET").
+					MatchQuery(QueryAnyOrder("tag", "a", "b"), QueryAbsent("debug"))
+
+				Expect(status(p, "/?tag=b&tag=a")).To(Equal(http.StatusOK))
+				Expect(status(p, "/?tag=b&tag=a&debug=1")).To(Equal(http.StatusForbidden))
+				Expect(status(p, "/?tag=a")).To(Equal(http.StatusForbidden))
+			})
+
+			g.It("should refuse unexpected params in strict mode", func() {
+				p := New().
+					SetMethods("GET").
+					SetParams(url.Values{"q": []string{"x"}}).
+					MatchQuery(QueryPresent("page"))
+
+				Expect(status(p, "/?q=x&page=2&extra=1")).To(Equal(http.StatusOK))
+
+				p.SetStrictQuery(true)
+
+				Expect(status(p, "/?q=x&page=2&extra=1")).To(Equal(http.StatusForbidden))
+				Expect(status(p, "/?q=x&page=2")).To(Equal(http.StatusOK))
+			})
+
+			g.It("should accept params named by expectations in strict mode", func() {
+				p := New().
+					SetMethods("GET").
+					SetStrictQuery(true)
+				p.Expect(QueryEquals("a", "1")).
+					Respond(Response{Status: http.StatusAccepted})
+
+				Expect(status(p, "/?a=1")).To(Equal(http.StatusAccepted))
+				Expect(status(p, "/?a=1&b=2")).To(Equal(http.StatusForbidden))
+
+				r := httptest.NewRequest("GET", "/?a=1&b=2", nil)
+				Expect(p.Explain(r, nil)).To(Equal([]string{"query b is not sent"}))
+			})
+		})
+
+		g.Describe("Verification", func() {
+			hit := func(p *Path, n int) {
+				for i := 0; i < n; i++ {
+					p.HandleRequest(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+				}
+			}
+
+			g.It("should verify declared hit counts", func() {
+				p := New().
+					SetMethods("GET")
+				Expect(p.Verify()).To(BeNil())
+
+				p.Times(2)
+				hit(p, 1)
+				Expect(p.Verify()).To(MatchError("expected exactly 2 hits, got 1"))
+
+				hit(p, 1)
+				Expect(p.Verify()).To(BeNil())
+
+				p.AtMost(1)
+				Expect(p.Verify()).To(MatchError("expected at most 1 hits, got 2"))
+
+				p.AtLeast(3)
+				Expect(p.Verify()).To(MatchError("expected at least 3 hits, got 2"))
+
+				p.Never()
+				Expect(p.Verify()).To(MatchError("expected exactly 0 hits, got 2"))
+			})
+
+			g.It("should explain why a request would not be accepted", func() {
+				p := New().
+					SetMethods("GET").
+					SetParams(url.Values{"q": []string{"x"}}).
+					RequireHeaders(http.StatusUnauthorized, HeaderPresent("Authorization"))
+				p.Expect(BodyEquals([]byte("a")), HeaderPresent("X-First")).Respond(Response{})
+				p.Expect(BodyEquals([]byte("b")), HeaderPresent("X-Other")).Respond(Response{})
+
+				r := httptest.NewRequest("POST", "/", nil)
+				Expect(p.Explain(r, []byte("c"))).To(Equal([]string{
+					`query q equals ["x"]`,
+					"header Authorization is present",
+					"method is one of [GET]",
+					`body equals "a"`,
+					"header X-First is present",
+				}))
+
+				r = httptest.NewRequest("GET", "/?q=x", nil)
+				r.Header.Set("Authorization", "yes")
+				Expect(p.Explain(r, []byte("b"))).To(Equal([]string{"header X-Other is present"}))
+				r.Header.Set("X-First", "yes")
+				Expect(p.Explain(r, []byte("a"))).To(BeEmpty())
+			})
+
+			g.It("should reset hit counts and response queues", func() {
+				p := New().
+					SetMethods("GET").
+					AddResponses(Response{Payload: []byte("first")}, Response{Payload: []byte("second")})
+				e := p.Expect(HeaderPresent("X-Expected")).
+					Respond(Response{Payload: []byte("one")}, Response{Payload: []byte("two")})
+
+				hit(p, 2)
+				r := httptest.NewRequest("GET", "/", nil)
+				r.Header.Set("X-Expected", "yes")
+				p.HandleRequest(httptest.NewRecorder(), r)
+				Expect(p.Hits()).To(Equal(1))
+				Expect(e.Hits()).To(Equal(1))
+
+				p.ResetHits()
+				Expect(p.Hits()).To(Equal(0))
+				Expect(e.Hits()).To(Equal(0))
+
+				w := httptest.NewRecorder()
+				p.HandleRequest(w, r)
+				Expect(w.Body.String()).To(Equal("one"))
+			})
+		})
+
+		g.Describe("Delays", func() {
+			g.It("should wait before responding", func() {
+				p := New().
+					SetMethods("GET").
+					SetDelay(20 * time.Millisecond)
+
+				start := time.Now()
+				w := httptest.NewRecorder()
+				p.HandleRequest(w, httptest.NewRequest("GET", "/", nil))
+
+				Expect(time.Since(start)).To(BeNumerically(">=", 20*time.Millisecond))
+				Expect(w.Code).To(Equal(http.StatusOK))
+			})
+
+			g.It("should stop waiting when the client goes away", func() {
+				p := New().
+					SetMethods("GET").
+					SetDelay(time.Minute)
+
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+
+				w := httptest.NewRecorder()
+				p.HandleRequest(w, httptest.NewRequest("GET", "/", nil).WithContext(ctx))
+
+				Expect(w.Body.Len()).To(Equal(0))
+			})
+
+			g.It("should draw reproducible latencies from a seeded source", func() {
+				draw := func(l Latency, seed int64) []time.Duration {
+					rng := rand.New(rand.NewSource(seed))
+					ds := make([]time.Duration, 50)
+					for i := range ds {
+						ds[i] = l.Duration(rng)
+					}
+					return ds
+				}
+
+				Expect(draw(Fixed(time.Second), 1)).NotTo(ContainElement(Not(Equal(time.Second))))
+				Expect(draw(Uniform(10*time.Millisecond, 20*time.Millisecond), 1)).NotTo(ContainElement(
+					Or(BeNumerically("<", 10*time.Millisecond), BeNumerically(">", 20*time.Millisecond)),
+				))
+				Expect(draw(Normal(time.Millisecond, time.Second), 1)).NotTo(ContainElement(BeNumerically("<", 0)))
+				Expect(draw(Exponential(time.Millisecond), 1)).NotTo(ContainElement(BeNumerically("<", 0)))
+
+				Expect(draw(Normal(time.Second, time.Millisecond), 7)).To(Equal(draw(Normal(time.Second, time.Millisecond), 7)))
+				Expect(draw(Exponential(time.Second), 7)).NotTo(Equal(draw(Exponential(time.Second), 8)))
+			})
+
+			g.It("should wait between chunks of the body", func() {
+				p := New().
+					SetMethods("GET").
+					SetPayload([]byte("abcdef")).
+					SetChunkLatency(2, Fixed(10*time.Millisecond))
+
+				start := time.Now()
+				w := httptest.NewRecorder()
+				p.HandleRequest(w, httptest.NewRequest("GET", "/", nil))
+
+				Expect(time.Since(start)).To(BeNumerically(">=", 20*time.Millisecond))
+				Expect(w.Body.String()).To(Equal("abcdef"))
+				Expect(w.Flushed).To(BeTrue())
+			})
+		})
+
+		g.Describe("Faults", func() {
+			g.It("should inject faults with the probability provided", func() {
+				p := New().
+					SetMethods("GET").
+					SetSeed(1).
+					SetFault(DropConnection, 0.5)
+
+				aborted := 0
+				for i := 0; i < 100; i++ {
+					func() {
+						defer func() {
+							if recover() == http.ErrAbortHandler {
+								aborted++
+							}
+						}()
+
+						p.HandleRequest(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+					}()
+				}
+
+				Expect(aborted).To(BeNumerically("~", 50, 20))
+				Expect(p.Hits()).To(Equal(100))
+
+				p.SetFault(DropConnection, 0)
+				w := httptest.NewRecorder()
+				p.HandleRequest(w, httptest.NewRequest("GET", "/", nil))
+				Expect(w.Code).To(Equal(http.StatusOK))
+			})
+
+			g.It("should stall until the client goes away", func() {
+				p := New().
+					SetMethods("GET").
+					SetFault(Stall, 1)
+
+				ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+				defer cancel()
+
+				w := httptest.NewRecorder()
+				Expect(func() {
+					p.HandleRequest(w, httptest.NewRequest("GET", "/", nil).WithContext(ctx))
+				}).To(PanicWith(http.ErrAbortHandler))
+
+				Expect(ctx.Err()).To(HaveOccurred())
+				Expect(w.Body.Len()).To(Equal(0))
+			})
+
+			g.It("should describe faults", func() {
+				Expect(TruncateBody.String()).To(Equal("truncated body"))
+				Expect(Fault(99).String()).To(Equal("unknown fault"))
+			})
+		})
+
+		g.Describe("Streaming", func() {
+			g.It("should stream chunks with flushes between them", func() {
+				p := New().
+					SetMethods("GET").
+					SetStream(
+						Chunk{Data: []byte("first,")},
+						Chunk{Data: []byte("second"), Delay: 50 * time.Millisecond},
+					)
+
+				server := httptest.NewServer(http.HandlerFunc(p.HandleRequest))
+				defer server.Close()
+
+				start := time.Now()
+				resp, err := http.Get(server.URL)
+				Expect(err).NotTo(HaveOccurred())
+				defer resp.Body.Close()
+
+				Expect(resp.TransferEncoding).To(Equal([]string{"chunked"}))
+
+				first := make([]byte, len("first,"))
+				_, err = io.ReadFull(resp.Body, first)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(first)).To(Equal("first,"))
+				Expect(time.Since(start)).To(BeNumerically("<", 50*time.Millisecond))
+
+				rest, _ := ioutil.ReadAll(resp.Body)
+				Expect(string(rest)).To(Equal("second"))
+				Expect(time.Since(start)).To(BeNumerically(">=", 50*time.Millisecond))
+			})
+
+			g.It("should stream server-sent events", func() {
+				p := New().
+					SetMethods("GET").
+					SetEvents(
+						Event{ID: "1", Event: "greeting", Data: "hello"},
+						Event{ID: "2", Data: "two\nlines", Retry: time.Second},
+						Event{ID: "3", Data: "last"},
+					)
+
+				w := httptest.NewRecorder()
+				p.HandleRequest(w, httptest.NewRequest("GET", "/", nil))
+
+				Expect(w.Header().Get("Content-Type")).To(Equal("text/event-stream"))
+				Expect(w.Body.String()).To(Equal(
+					"id: 1\nevent: greeting\ndata: hello\n\n" +
+						"id: 2\nretry: 1000\ndata: two\ndata: lines\n\n" +
+						"id: 3\ndata: last\n\n",
+				))
+
+				r := httptest.NewRequest("GET", "/", nil)
+				r.Header.Set("Last-Event-ID", "2")
+				w = httptest.NewRecorder()
+				p.HandleRequest(w, r)
+
+				Expect(w.Body.String()).To(Equal("id: 3\ndata: last\n\n"))
+				Expect(p.Hits()).To(Equal(2))
+			})
+		})
+
+		g.Describe("Content Negotiation", func() {
+			var p *Path
+
+			negotiate := func(header http.Header) *httptest.ResponseRecorder {
+				r := httptest.NewRequest("GET", "/", nil)
+				for k, v := range header {
+					r.Header[k] = v
+				}
+
+				w := httptest.NewRecorder()
+				p.HandleRequest(w, r)
+				return w
+			}
+
+			g.BeforeEach(func() {
+				p = New().
+					SetMethods("GET").
+					AddRepresentations(
+						Representation{MediaType: "application/json", Language: "en", Payload: []byte(`{"greeting":"hello"}`)},
+						Representation{MediaType: "application/json", Language: "fr", Payload: []byte(`{"greeting":"bonjour"}`)},
+						Representation{MediaType: "text/plain", Language: "en", Payload: []byte("hello")},
+						Representation{MediaType: "text/plain", Language: "en", Encoding: "gzip", Payload: []byte("gzipped hello")},
+					)
+			})
+
+			g.It("should pick the first representation without preferences", func() {
+				w := negotiate(nil)
+
+				Expect(w.Code).To(Equal(http.StatusOK))
+				Expect(w.Header().Get("Content-Type")).To(Equal("application/json"))
+				Expect(w.Header().Get("Content-Language")).To(Equal("en"))
+				Expect(w.Header().Get("Vary")).To(Equal("Accept, Accept-Language, Accept-Encoding"))
+			})
+
+			g.It("should honour q-values", func() {
+				w := negotiate(http.Header{
+					"Accept":          {"application/json;q=0.5, text/*;q=0.9"},
+					"Accept-Language": {"fr-CA, en;q=0.1"},
+				})
+				Expect(w.Body.String()).To(Equal("hello"))
+
+				w = negotiate(http.Header{
+					"Accept":          {"*/*;q=0.1, application/json"},
+					"Accept-Language": {"fr;q=0.8, en-US;q=0.4, *;q=0.1"},
+				})
+				Expect(w.Body.String()).To(Equal(`{"greeting":"bonjour"}`))
+
+				w = negotiate(http.Header{
+					"Accept":          {"text/plain"},
+					"Accept-Encoding": {"gzip, identity;q=0.5"},
+				})
+				Expect(w.Body.String()).To(Equal("gzipped hello"))
+				Expect(w.Header().Get("Content-Encoding")).To(Equal("gzip"))
+
+				w = negotiate(http.Header{
+					"Accept":          {"text/plain"},
+					"Accept-Encoding": {"br"},
+				})
+				Expect(w.Body.String()).To(Equal("hello"))
+			})
+
+			g.It("should answer not acceptable when nothing fits", func() {
+				w := negotiate(http.Header{"Accept": {"image/png, text/plain;q=0"}})
+				Expect(w.Code).To(Equal(http.StatusNotAcceptable))
+				Expect(w.Header().Get("Vary")).To(Equal("Accept, Accept-Language, Accept-Encoding"))
+
+				w = negotiate(http.Header{"Accept-Language": {"de"}})
+				Expect(w.Code).To(Equal(http.StatusNotAcceptable))
+
+				Expect(p.Hits()).To(Equal(0))
+			})
+
+			g.It("should vary on the headers used to choose", func() {
+				p = New().
+					SetMethods("GET").
+					AddRepresentations(
+						Representation{MediaType: "application/json", Payload: []byte("{}")},
+						Representation{MediaType: "application/xml", Payload: []byte("<a/>")},
+					)
+
+				w := negotiate(http.Header{"Accept": {"application/xml"}})
+				Expect(w.Body.String()).To(Equal("<a/>"))
+				Expect(w.Header().Get("Vary")).To(Equal("Accept"))
+
+				p = New().
+					SetMethods("GET").
+					AddRepresentations(
+						Representation{MediaType: "application/json", Language: "en", Payload: []byte("{}")},
+					)
+
+				w = negotiate(http.Header{"Accept": {"application/json"}})
+				Expect(w.Code).To(Equal(http.StatusOK))
+				Expect(w.Header().Get("Vary")).To(Equal("Accept, Accept-Language"))
+
+				w = negotiate(http.Header{"Accept": {"text/html"}})
+				Expect(w.Code).To(Equal(http.StatusNotAcceptable))
+				Expect(w.Header().Get("Vary")).To(Equal("Accept, Accept-Language"))
+			})
+		})
+
+		g.Describe("Conditional Requests", func() {
+			modified := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+			conditional := func(p *Path, method string, header http.Header) *httptest.ResponseRecorder {
+				r := httptest.NewRequest(method, "/", nil)
+				for k, v := range header {
+					r.Header[k] = v
+				}
+
+				w := httptest.NewRecorder()
+				p.HandleRequest(w, r)
+				return w
+			}
+
+			g.It("should generate entity tags and answer not modified", func() {
+				p := New().
+					SetMethods("GET").
+					SetPayload([]byte("cached")).
+					SetETag("")
+
+				w := conditional(p, "GET", nil)
+				etag := w.Header().Get("ETag")
+				Expect(etag).To(MatchRegexp(`^"[0-9a-f]{40}"$`))
+				Expect(w.Body.String()).To(Equal("cached"))
+
+				w = conditional(p, "GET", http.Header{"If-None-Match": {`"other", W/` + etag}})
+				Expect(w.Code).To(Equal(http.StatusNotModified))
+				Expect(w.Body.Len()).To(Equal(0))
+				Expect(w.Header().Get("ETag")).To(Equal(etag))
+
+				w = conditional(p, "GET", http.Header{"If-None-Match": {`"other"`}})
+				Expect(w.Code).To(Equal(http.StatusOK))
+
+				p.SetPayload([]byte("changed"))
+				w = conditional(p, "GET", http.Header{"If-None-Match": {etag}})
+				Expect(w.Code).To(Equal(http.StatusOK))
+				Expect(w.Header().Get("ETag")).NotTo(Equal(etag))
+			})
+
+			g.It("should not generate entity tags for dynamic responses", func() {
+				p := New().
+					SetMethods("GET").
+					SetETag("").
+					SetResponder(func(*http.Request) Response {
+						return Response{Payload: []byte("dynamic")}
+					})
+
+				w := conditional(p, "GET", http.Header{"If-None-Match": {"*"}})
+				Expect(w.Code).To(Equal(http.StatusOK))
+				Expect(w.Header().Get("ETag")).To(BeEmpty())
+				Expect(w.Body.String()).To(Equal("dynamic"))
+			})
+
+			g.It("should use configured entity tags for preconditions", func() {
+				p := New().
+					SetMethods("GET", "PUT").
+					SetETag("v1")
+
+				w := conditional(p, "PUT", http.Header{"If-Match": {`"v1"`}})
+				Expect(w.Code).To(Equal(http.StatusOK))
+				Expect(w.Header().Get("ETag")).To(Equal(`"v1"`))
+
+				w = conditional(p, "PUT", http.Header{"If-Match": {`"v0"`}})
+				Expect(w.Code).To(Equal(http.StatusPreconditionFailed))
+
+				w = conditional(p, "PUT", http.Header{"If-None-Match": {"*"}})
+				Expect(w.Code).To(Equal(http.StatusPreconditionFailed))
+
+				p.SetETag("W/v2")
+				w = conditional(p, "PUT", http.Header{"If-Match": {`W/"v2"`}})
+				Expect(w.Code).To(Equal(http.StatusPreconditionFailed))
+
+				w = conditional(p, "GET", http.Header{"If-None-Match": {`"v2"`}})
+				Expect(w.Code).To(Equal(http.StatusNotModified))
+			})
+
+			g.It("should not use up queued responses when short circuiting", func() {
+				p := New().
+					SetMethods("GET").
+					SetETag("v1").
+					AddResponses(
+						Response{Payload: []byte("a")},
+						Response{Payload: []byte("b")},
+						Response{Payload: []byte("c")},
+					)
+
+				for i := 0; i < 3; i++ {
+					w := conditional(p, "GET", http.Header{"If-None-Match": {`"v1"`}})
+					Expect(w.Code).To(Equal(http.StatusNotModified))
+				}
+
+				w := conditional(p, "GET", nil)
+				Expect(w.Body.String()).To(Equal("a"))
+				Expect(p.Hits()).To(Equal(1))
+
+				e := p.Expect(HeaderMatches("X-Mode", "test")).
+					Respond(Response{Payload: []byte("first")}, Response{Payload: []byte("second")})
+
+				w = conditional(p, "GET", http.Header{"X-Mode": {"test"}, "If-None-Match": {`"v1"`}})
+				Expect(w.Code).To(Equal(http.StatusNotModified))
+
+				w = conditional(p, "GET", http.Header{"X-Mode": {"test"}})
+				Expect(w.Body.String()).To(Equal("first"))
+				Expect(e.Hits()).To(Equal(1))
+			})
+
+			g.It("should compare modification times", func() {
+				p := New().
+					SetMethods("GET", "DELETE").
+					SetLastModified(modified.Add(500 * time.Millisecond))
+
+				w := conditional(p, "GET", nil)
+				Expect(w.Header().Get("Last-Modified")).To(Equal("Thu, 02 Jan 2020 03:04:05 GMT"))
+
+				w = conditional(p, "GET", http.Header{"If-Modified-Since": {modified.Format(http.TimeFormat)}})
+				Expect(w.Code).To(Equal(http.StatusNotModified))
+
+				w = conditional(p, "GET", http.Header{"If-Modified-Since": {modified.Add(-time.Second).Format(http.TimeFormat)}})
+				Expect(w.Code).To(Equal(http.StatusOK))
+
+				w = conditional(p, "GET", http.Header{"If-Modified-Since": {"yesterday"}})
+				Expect(w.Code).To(Equal(http.StatusOK))
+
+				w = conditional(p, "DELETE", http.Header{"If-Unmodified-Since": {modified.Add(-time.Hour).Format(http.TimeFormat)}})
+				Expect(w.Code).To(Equal(http.StatusPreconditionFailed))
+
+				w = conditional(p, "DELETE", http.Header{"If-Unmodified-Since": {modified.Format(http.TimeFormat)}})
+				Expect(w.Code).To(Equal(http.StatusOK))
+			})
+		})
 	})
 }
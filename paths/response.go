@@ -0,0 +1,56 @@
+package paths
+
+import (
+	"net/http"
+	"time"
+)
+
+// Response represents a single response a path can reply with. A zero Status
+// is treated as 200 OK. Header carries headers sent more than once, such as
+// Set-Cookie, and is added after Headers. A Delay is waited out before the
+// response is written, on top of any latency set on the path.
+type Response struct {
+	Status  int
+	Headers map[string]string
+	Header  http.Header
+	Payload []byte
+	Delay   time.Duration
+}
+
+// Exhaustion represents what a path does once its queue of responses has been
+// used up
+type Exhaustion int
+
+const (
+	// RepeatLast keeps replying with the last queued response
+	RepeatLast Exhaustion = iota
+	// Cycle starts over from the first queued response
+	Cycle
+	// Fallback replies with the status, headers, and payload set directly on
+	// the path
+	Fallback
+)
+
+func (resp Response) write(w http.ResponseWriter) {
+	resp.setHeaders(w)
+
+	status := resp.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	w.WriteHeader(status)
+	w.Write(resp.Payload) //nolint,errcheck
+}
+
+func (resp Response) setHeaders(w http.ResponseWriter) {
+	for header, value := range resp.Headers {
+		w.Header().Set(header, value)
+	}
+
+	for header, values := range resp.Header {
+		for _, value := range values {
+			w.Header().Add(header, value)
+		}
+	}
+}
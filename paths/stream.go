@@ -0,0 +1,134 @@
+package paths
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gomicro/bogus/internal/timing"
+)
+
+// Chunk represents a piece of a streamed response body, written once its
+// delay has passed
+type Chunk struct {
+	Data  []byte
+	Delay time.Duration
+}
+
+// Event represents a server-sent event, written once its delay has passed
+type Event struct {
+	ID    string
+	Event string
+	Data  string
+	Retry time.Duration
+	Delay time.Duration
+}
+
+// stream is a streamed response body, built for each request
+type stream struct {
+	contentType string
+	chunks      func(*http.Request) []Chunk
+}
+
+// SetStream sets chunks to stream as the response body, flushing each to the
+// client as it is written, and returns the path for additional configuration.
+// Method and param checks still apply, and hits are still counted. A handler
+// or responder takes precedence over it.
+func (p *Path) SetStream(chunks ...Chunk) *Path {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.stream = &stream{
+		chunks: func(*http.Request) []Chunk {
+			return chunks
+		},
+	}
+	return p
+}
+
+// SetEvents sets server-sent events to stream as the response body and
+// returns the path for additional configuration. A client reconnecting with a
+// Last-Event-ID header is sent only the events after the one with that id.
+func (p *Path) SetEvents(events ...Event) *Path {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.stream = &stream{
+		contentType: "text/event-stream",
+		chunks: func(r *http.Request) []Chunk {
+			remaining := events
+
+			if last := r.Header.Get("Last-Event-ID"); last != "" {
+				for i, e := range events {
+					if e.ID == last {
+						remaining = events[i+1:]
+						break
+					}
+				}
+			}
+
+			chunks := make([]Chunk, len(remaining))
+			for i, e := range remaining {
+				chunks[i] = Chunk{Data: e.frame(), Delay: e.Delay}
+			}
+
+			return chunks
+		},
+	}
+	return p
+}
+
+// frame formats the event as it is sent on the wire
+func (e Event) frame() []byte {
+	var sb strings.Builder
+
+	if e.ID != "" {
+		sb.WriteString("id: " + e.ID + "\n")
+	}
+
+	if e.Event != "" {
+		sb.WriteString("event: " + e.Event + "\n")
+	}
+
+	if e.Retry > 0 {
+		sb.WriteString(fmt.Sprintf("retry: %d\n", e.Retry.Milliseconds()))
+	}
+
+	for _, line := range strings.Split(e.Data, "\n") {
+		sb.WriteString("data: " + line + "\n")
+	}
+
+	sb.WriteString("\n")
+	return []byte(sb.String())
+}
+
+// write streams the chunks for the request to the client, stopping early if
+// the client goes away
+func (s *stream) write(w http.ResponseWriter, r *http.Request, status int) {
+	if s.contentType != "" {
+		w.Header().Set("Content-Type", s.contentType)
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+
+	w.WriteHeader(status)
+
+	flusher, _ := w.(http.Flusher)
+	if flusher != nil {
+		flusher.Flush()
+	}
+
+	for _, c := range s.chunks(r) {
+		if !timing.Wait(r, c.Delay) {
+			return
+		}
+
+		if _, err := w.Write(c.Data); err != nil {
+			return
+		}
+
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
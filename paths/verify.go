@@ -0,0 +1,122 @@
+package paths
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Times declares the path should be hit exactly n times and returns the path
+// for additional configuration
+func (p *Path) Times(n int) *Path {
+	return p.setBounds(n, n)
+}
+
+// AtLeast declares the path should be hit n or more times and returns the path
+// for additional configuration
+func (p *Path) AtLeast(n int) *Path {
+	return p.setBounds(n, -1)
+}
+
+// AtMost declares the path should be hit no more than n times and returns the
+// path for additional configuration
+func (p *Path) AtMost(n int) *Path {
+	return p.setBounds(0, n)
+}
+
+// Never declares the path should not be hit at all and returns the path for
+// additional configuration
+func (p *Path) Never() *Path {
+	return p.setBounds(0, 0)
+}
+
+func (p *Path) setBounds(min, max int) *Path {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.bounded = true
+	p.minHits = min
+	p.maxHits = max
+	return p
+}
+
+// Verify returns an error describing how the path's hits fall outside of what
+// was declared with Times, AtLeast, AtMost, or Never. It returns nil if they
+// do not, or if nothing was declared.
+func (p *Path) Verify() error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if !p.bounded {
+		return nil
+	}
+
+	hits := p.Hits()
+
+	switch {
+	case p.minHits == p.maxHits && hits != p.minHits:
+		return fmt.Errorf("expected exactly %v hits, got %v", p.minHits, hits)
+	case hits < p.minHits:
+		return fmt.Errorf("expected at least %v hits, got %v", p.minHits, hits)
+	case p.maxHits >= 0 && hits > p.maxHits:
+		return fmt.Errorf("expected at most %v hits, got %v", p.maxHits, hits)
+	}
+
+	return nil
+}
+
+// Explain returns a description of each condition the path places on requests
+// that the request provided does not meet. It returns nothing if the path
+// would accept the request.
+func (p *Path) Explain(r *http.Request, body []byte) []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	reasons := []string{}
+
+	vars := query(r)
+	for param, value := range p.params {
+		if passed, ok := vars[param]; !ok || !equalValues(passed, value) {
+			reasons = append(reasons, fmt.Sprintf("query %v equals %q", param, value))
+		}
+	}
+
+	if p.strictQuery {
+		for param := range vars {
+			if !p.expectsParam(param) {
+				reasons = append(reasons, fmt.Sprintf("query %v is not sent", param))
+			}
+		}
+	}
+
+	for _, c := range p.checks {
+		if !c.matcher.Match(r, body) {
+			reasons = append(reasons, c.matcher.String())
+		}
+	}
+
+	if _, ok := p.methodResponses[strings.ToUpper(r.Method)]; !ok && !p.hasMethod(r.Method) {
+		reasons = append(reasons, fmt.Sprintf("method is one of %v", p.allowed()))
+	}
+
+	var closest []string
+	for i, e := range p.expectations {
+		unmet := []string{}
+		for _, m := range e.matchers {
+			if !m.Match(r, body) {
+				unmet = append(unmet, m.String())
+			}
+		}
+
+		if len(unmet) == 0 {
+			closest = nil
+			break
+		}
+
+		if i == 0 || len(unmet) < len(closest) {
+			closest = unmet
+		}
+	}
+
+	return append(reasons, closest...)
+}
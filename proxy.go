@@ -0,0 +1,199 @@
+package bogus
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/gomicro/bogus/paths"
+)
+
+// Exchange is a single request recorded while proxying and the response the
+// upstream replied with
+type Exchange struct {
+	Method         string      `json:"method"`
+	Path           string      `json:"path"`
+	Query          string      `json:"query,omitempty"`
+	RequestHeader  http.Header `json:"requestHeader,omitempty"`
+	RequestBody    []byte      `json:"requestBody,omitempty"`
+	Status         int         `json:"status"`
+	ResponseHeader http.Header `json:"responseHeader,omitempty"`
+	ResponseBody   []byte      `json:"responseBody,omitempty"`
+}
+
+// Recording is a set of exchanges as saved to and loaded from a fixture file
+type Recording struct {
+	Exchanges []Exchange `json:"exchanges"`
+}
+
+// skippedHeaders are response headers describing a single transfer rather than
+// the response itself, so they are left out when replaying
+var skippedHeaders = map[string]bool{
+	"Connection":        true,
+	"Content-Length":    true,
+	"Date":              true,
+	"Keep-Alive":        true,
+	"Transfer-Encoding": true,
+}
+
+// WithProxy forwards requests not routed to any path to the upstream URL
+// provided, recording each exchange along with the response in the hit
+// records. It panics if the URL cannot be parsed.
+func WithProxy(upstream string) Option {
+	target, err := url.Parse(upstream)
+	if err != nil {
+		panic("bogus: invalid proxy upstream " + upstream + ": " + err.Error())
+	}
+
+	return func(c *config) {
+		c.proxy = httputil.NewSingleHostReverseProxy(target)
+	}
+}
+
+// Recording returns the exchanges forwarded to the upstream so far. Responses
+// compressed by WithCompression are recorded as they were sent, compressed,
+// so their bodies agree with their headers.
+func (b *Bogus) Recording() Recording {
+	rec := Recording{Exchanges: []Exchange{}}
+
+	for _, h := range b.HitRecords() {
+		if !h.Proxied {
+			continue
+		}
+
+		body := h.ResponseBody
+		if h.RawResponseBody != nil {
+			body = h.RawResponseBody
+		}
+
+		rec.Exchanges = append(rec.Exchanges, Exchange{
+			Method:         h.Verb,
+			Path:           h.Path,
+			Query:          h.Query.Encode(),
+			RequestHeader:  h.Header,
+			RequestBody:    h.Body,
+			Status:         h.Status,
+			ResponseHeader: h.ResponseHeader,
+			ResponseBody:   body,
+		})
+	}
+
+	return rec
+}
+
+// SaveRecording writes the exchanges forwarded to the upstream so far to a
+// JSON fixture file
+func (b *Bogus) SaveRecording(filename string) error {
+	data, err := json.MarshalIndent(b.Recording(), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filename, data, 0644)
+}
+
+// LoadRecording reads a fixture file written by SaveRecording and replays it
+// with ReplayRecording
+func (b *Bogus) LoadRecording(filename string) error {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	var rec Recording
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return err
+	}
+
+	b.ReplayRecording(rec)
+	return nil
+}
+
+// ReplayRecording registers paths answering each recorded request with the
+// response recorded for it. Requests are told apart by method, query, and
+// body, and identical requests recorded more than once are answered with each
+// of their responses in turn. Paths are added in the order they were first
+// recorded.
+func (b *Bogus) ReplayRecording(rec Recording) {
+	type key struct {
+		method, query, body string
+	}
+
+	byPath := map[string][]key{}
+	order := []string{}
+	responses := map[string]map[key][]paths.Response{}
+
+	for _, ex := range rec.Exchanges {
+		k := key{
+			method: strings.ToUpper(ex.Method),
+			query:  ex.Query,
+			body:   string(ex.RequestBody),
+		}
+
+		if responses[ex.Path] == nil {
+			responses[ex.Path] = map[key][]paths.Response{}
+			order = append(order, ex.Path)
+		}
+
+		if _, ok := responses[ex.Path][k]; !ok {
+			byPath[ex.Path] = append(byPath[ex.Path], k)
+		}
+
+		responses[ex.Path][k] = append(responses[ex.Path][k], exchangeResponse(ex))
+	}
+
+	for _, path := range order {
+		keys := byPath[path]
+		methods := []string{}
+		seen := map[string]bool{}
+		for _, k := range keys {
+			if !seen[k.method] {
+				seen[k.method] = true
+				methods = append(methods, k.method)
+			}
+		}
+
+		// more specific requests are tried first so they are not shadowed by
+		// requests sharing a subset of their query
+		sort.SliceStable(keys, func(i, j int) bool {
+			return len(keys[i].query) > len(keys[j].query)
+		})
+
+		p := b.AddPath(path).
+			SetMethods(methods...)
+
+		for _, k := range keys {
+			matchers := []paths.Matcher{
+				paths.MethodEquals(k.method),
+				paths.BodyEquals([]byte(k.body)),
+			}
+
+			query, _ := url.ParseQuery(k.query)
+			for name, values := range query {
+				matchers = append(matchers, paths.QueryEquals(name, values...))
+			}
+
+			p.Expect(matchers...).
+				Respond(responses[path][k]...)
+		}
+	}
+}
+
+func exchangeResponse(ex Exchange) paths.Response {
+	header := http.Header{}
+	for name, values := range ex.ResponseHeader {
+		if !skippedHeaders[http.CanonicalHeaderKey(name)] {
+			header[name] = append([]string(nil), values...)
+		}
+	}
+
+	return paths.Response{
+		Status:  ex.Status,
+		Header:  header,
+		Payload: ex.ResponseBody,
+	}
+}
@@ -0,0 +1,236 @@
+package bogus
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/franela/goblin"
+	"github.com/gomicro/bogus/paths"
+	. "github.com/onsi/gomega"
+)
+
+func TestProxy(t *testing.T) {
+	g := goblin.Goblin(t)
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("Record and Replay", func() {
+		var upstream *Bogus
+
+		g.BeforeEach(func() {
+			upstream = New()
+			upstream.AddPath("/users/{id}").
+				SetMethods("GET").
+				SetResponder(func(r *http.Request) paths.Response {
+					return paths.Response{
+						Headers: map[string]string{"Content-Type": "application/json"},
+						Payload: []byte(`{"id":"` + paths.Params(r)["id"] + `"}`),
+					}
+				})
+			upstream.AddPath("/session").
+				SetMethods("GET").
+				SetHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.Header().Add("Set-Cookie", "a=1")
+					w.Header().Add("Set-Cookie", "b=2")
+				})
+			upstream.AddPath("/orders").
+				SetMethods("POST").
+				AddResponses(
+					paths.Response{Status: http.StatusServiceUnavailable},
+					paths.Response{Status: http.StatusCreated, Payload: []byte("created")},
+				)
+		})
+
+		g.AfterEach(func() {
+			upstream.Close()
+		})
+
+		get := func(client *http.Client, url string) (int, string) {
+			resp, err := client.Get(url)
+			Expect(err).NotTo(HaveOccurred())
+			defer resp.Body.Close()
+
+			body, _ := ioutil.ReadAll(resp.Body)
+			return resp.StatusCode, string(body)
+		}
+
+		post := func(client *http.Client, url, body string) (int, string) {
+			resp, err := client.Post(url, "text/plain", bytes.NewBufferString(body))
+			Expect(err).NotTo(HaveOccurred())
+			defer resp.Body.Close()
+
+			respBody, _ := ioutil.ReadAll(resp.Body)
+			return resp.StatusCode, string(respBody)
+		}
+
+		g.It("should proxy and record exchanges", func() {
+			proxy := New(WithProxy(upstream.URL()))
+			defer proxy.Close()
+
+			proxy.AddPath("/local").
+				SetMethods("GET").
+				SetPayload([]byte("local"))
+
+			status, body := get(proxy.Client(), proxy.URL()+"/users/42?fields=all")
+			Expect(status).To(Equal(http.StatusOK))
+			Expect(body).To(Equal(`{"id":"42"}`))
+
+			status, body = get(proxy.Client(), proxy.URL()+"/local")
+			Expect(status).To(Equal(http.StatusOK))
+			Expect(body).To(Equal("local"))
+
+			records := proxy.HitRecords()
+			Expect(records).To(HaveLen(2))
+			Expect(records[0].Proxied).To(BeTrue())
+			Expect(records[0].Status).To(Equal(http.StatusOK))
+			Expect(records[0].ResponseHeader.Get("Content-Type")).To(Equal("application/json"))
+			Expect(string(records[0].ResponseBody)).To(Equal(`{"id":"42"}`))
+			Expect(records[1].Proxied).To(BeFalse())
+			Expect(string(records[1].ResponseBody)).To(Equal("local"))
+
+			Expect(upstream.Hits()).To(Equal(1))
+			Expect(proxy.Recording().Exchanges).To(HaveLen(1))
+		})
+
+		g.It("should replay saved recordings", func() {
+			proxy := New(WithProxy(upstream.URL()))
+			defer proxy.Close()
+
+			get(proxy.Client(), proxy.URL()+"/users/42")
+			get(proxy.Client(), proxy.URL()+"/users/7?fields=all")
+			post(proxy.Client(), proxy.URL()+"/orders", "order")
+			post(proxy.Client(), proxy.URL()+"/orders", "order")
+
+			dir, err := ioutil.TempDir("", "bogus")
+			Expect(err).NotTo(HaveOccurred())
+			defer os.RemoveAll(dir)
+
+			fixture := filepath.Join(dir, "recording.json")
+			Expect(proxy.SaveRecording(fixture)).To(Succeed())
+
+			replay := New()
+			defer replay.Close()
+			Expect(replay.LoadRecording(fixture)).To(Succeed())
+
+			status, body := get(replay.Client(), replay.URL()+"/users/42")
+			Expect(status).To(Equal(http.StatusOK))
+			Expect(body).To(Equal(`{"id":"42"}`))
+
+			status, body = get(replay.Client(), replay.URL()+"/users/7?fields=all")
+			Expect(status).To(Equal(http.StatusOK))
+			Expect(body).To(Equal(`{"id":"7"}`))
+
+			status, _ = post(replay.Client(), replay.URL()+"/orders", "order")
+			Expect(status).To(Equal(http.StatusServiceUnavailable))
+
+			status, body = post(replay.Client(), replay.URL()+"/orders", "order")
+			Expect(status).To(Equal(http.StatusCreated))
+			Expect(body).To(Equal("created"))
+
+			status, _ = post(replay.Client(), replay.URL()+"/orders", "other")
+			Expect(status).To(Equal(http.StatusForbidden))
+
+			resp, err := replay.Client().Get(replay.URL() + "/users/42")
+			Expect(err).NotTo(HaveOccurred())
+			resp.Body.Close()
+			Expect(resp.Header.Get("Content-Type")).To(Equal("application/json"))
+
+			Expect(upstream.Hits()).To(Equal(4))
+		})
+
+		g.It("should replay responses it compressed while recording", func() {
+			proxy := New(WithProxy(upstream.URL()), WithCompression())
+			defer proxy.Close()
+
+			status, body := get(proxy.Client(), proxy.URL()+"/users/42")
+			Expect(status).To(Equal(http.StatusOK))
+			Expect(body).To(Equal(`{"id":"42"}`))
+
+			rec := proxy.Recording()
+			Expect(rec.Exchanges[0].ResponseHeader.Get("Content-Encoding")).To(Equal("gzip"))
+
+			replay := New()
+			defer replay.Close()
+			replay.ReplayRecording(rec)
+
+			status, body = get(replay.Client(), replay.URL()+"/users/42")
+			Expect(status).To(Equal(http.StatusOK))
+			Expect(body).To(Equal(`{"id":"42"}`))
+		})
+
+		g.It("should replay headers sent more than once", func() {
+			proxy := New(WithProxy(upstream.URL()))
+			defer proxy.Close()
+
+			get(proxy.Client(), proxy.URL()+"/session")
+
+			replay := New()
+			defer replay.Close()
+			replay.ReplayRecording(proxy.Recording())
+
+			resp, err := replay.Client().Get(replay.URL() + "/session")
+			Expect(err).NotTo(HaveOccurred())
+			resp.Body.Close()
+			Expect(resp.Header.Values("Set-Cookie")).To(Equal([]string{"a=1", "b=2"}))
+		})
+
+		g.It("should add replayed paths in the order they were recorded", func() {
+			rec := Recording{}
+			for i := 0; i < 8; i++ {
+				rec.Exchanges = append(rec.Exchanges, Exchange{
+					Method: "GET",
+					Path:   fmt.Sprintf("/p%v", i),
+					Status: http.StatusOK,
+				})
+			}
+
+			faults := func() []paths.Fault {
+				replay := New(WithSeed(42))
+				defer replay.Close()
+				replay.ReplayRecording(rec)
+
+				for _, ex := range rec.Exchanges {
+					replay.AddPath(ex.Path).
+						SetFault(paths.DropConnection, 0.5)
+
+					for i := 0; i < 4; i++ {
+						resp, err := replay.Client().Get(replay.URL() + ex.Path)
+						if err == nil {
+							resp.Body.Close()
+						}
+					}
+				}
+
+				faults := []paths.Fault{}
+				for _, hr := range replay.HitRecords() {
+					faults = append(faults, hr.Fault)
+				}
+				return faults
+			}
+
+			Expect(faults()).To(Equal(faults()))
+		})
+
+		g.It("should record responses for every request", func() {
+			server := New()
+			defer server.Close()
+
+			server.AddPath("/").
+				SetMethods("GET").
+				SetStatus(http.StatusTeapot).
+				SetPayload([]byte("short and stout"))
+
+			get(server.Client(), server.URL())
+			get(server.Client(), server.URL()+"/missing")
+
+			records := server.HitRecords()
+			Expect(records[0].Status).To(Equal(http.StatusTeapot))
+			Expect(string(records[0].ResponseBody)).To(Equal("short and stout"))
+			Expect(records[1].Status).To(Equal(http.StatusNotFound))
+		})
+	})
+}
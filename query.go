@@ -0,0 +1,191 @@
+package bogus
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// HitQuery filters the hit records of a server. Each filter returns a new
+// query, so a query may be narrowed in several ways without affecting the
+// original. Records are read when the query is evaluated by All, Count,
+// First, Last, or One, and the results are a snapshot which will not change as
+// further hits arrive.
+type HitQuery struct {
+	b       *Bogus
+	filters []hitFilter
+}
+
+type hitFilter struct {
+	desc  string
+	match func(HitRecord) bool
+}
+
+// Query returns a query over all of the server's hit records
+func (b *Bogus) Query() *HitQuery {
+	return &HitQuery{b: b}
+}
+
+// Where narrows the query to records the function returns true for
+func (q *HitQuery) Where(desc string, fn func(HitRecord) bool) *HitQuery {
+	filters := make([]hitFilter, len(q.filters), len(q.filters)+1)
+	copy(filters, q.filters)
+
+	return &HitQuery{
+		b:       q.b,
+		filters: append(filters, hitFilter{desc: desc, match: fn}),
+	}
+}
+
+// Method narrows the query to requests made with the method provided
+func (q *HitQuery) Method(method string) *HitQuery {
+	return q.Where("method is "+method, func(h HitRecord) bool {
+		return strings.EqualFold(h.Verb, method)
+	})
+}
+
+// Path narrows the query to requests for exactly the path provided
+func (q *HitQuery) Path(path string) *HitQuery {
+	return q.Where("path is "+path, func(h HitRecord) bool {
+		return h.Path == path
+	})
+}
+
+// Route narrows the query to requests handled by the path added with the
+// pattern provided, such as /users/{id}
+func (q *HitQuery) Route(pattern string) *HitQuery {
+	return q.Where("route is "+pattern, func(h HitRecord) bool {
+		return h.Route == pattern
+	})
+}
+
+// Header narrows the query to requests carrying the header with the value
+// provided among its values
+func (q *HitQuery) Header(name, value string) *HitQuery {
+	return q.Where(fmt.Sprintf("header %v is %q", name, value), func(h HitRecord) bool {
+		return contains(h.Header.Values(name), value)
+	})
+}
+
+// QueryParam narrows the query to requests carrying the query param with the
+// value provided among its values
+func (q *HitQuery) QueryParam(name, value string) *HitQuery {
+	return q.Where(fmt.Sprintf("query %v is %q", name, value), func(h HitRecord) bool {
+		return contains(h.Query[name], value)
+	})
+}
+
+// Body narrows the query to requests whose body the function returns true for
+func (q *HitQuery) Body(fn func(body []byte) bool) *HitQuery {
+	return q.Where("body matches", func(h HitRecord) bool {
+		return fn(h.Body)
+	})
+}
+
+// Since narrows the query to requests arriving at or after the time provided
+func (q *HitQuery) Since(t time.Time) *HitQuery {
+	return q.Where("arrived since "+t.Format(time.RFC3339Nano), func(h HitRecord) bool {
+		return !h.Time.Before(t)
+	})
+}
+
+// Until narrows the query to requests arriving before the time provided
+func (q *HitQuery) Until(t time.Time) *HitQuery {
+	return q.Where("arrived before "+t.Format(time.RFC3339Nano), func(h HitRecord) bool {
+		return h.Time.Before(t)
+	})
+}
+
+// All returns the records matching the query in the order they arrived
+func (q *HitQuery) All() []HitRecord {
+	records := []HitRecord{}
+
+	for _, rec := range q.b.HitRecords() {
+		if q.matches(rec) {
+			records = append(records, rec)
+		}
+	}
+
+	return records
+}
+
+// Count returns the number of records matching the query
+func (q *HitQuery) Count() int {
+	return len(q.All())
+}
+
+// First returns the earliest record matching the query, and false if there
+// are none
+func (q *HitQuery) First() (HitRecord, bool) {
+	records := q.All()
+	if len(records) == 0 {
+		return HitRecord{}, false
+	}
+
+	return records[0], true
+}
+
+// Last returns the latest record matching the query, and false if there are
+// none
+func (q *HitQuery) Last() (HitRecord, bool) {
+	records := q.All()
+	if len(records) == 0 {
+		return HitRecord{}, false
+	}
+
+	return records[len(records)-1], true
+}
+
+// One returns the single record matching the query, reporting an error on the
+// test if there is not exactly one
+func (q *HitQuery) One(t testing.TB) HitRecord {
+	t.Helper()
+
+	records := q.All()
+	if len(records) != 1 {
+		msg := fmt.Sprintf("bogus: expected one request where %v, got %v", q, len(records))
+		for _, rec := range records {
+			msg += "\n\t" + indent(rec.String(), "\t")
+		}
+		t.Errorf("%v", msg)
+
+		return HitRecord{}
+	}
+
+	return records[0]
+}
+
+// String returns a description of the query's filters
+func (q *HitQuery) String() string {
+	if len(q.filters) == 0 {
+		return "any request"
+	}
+
+	descs := make([]string, len(q.filters))
+	for i, f := range q.filters {
+		descs[i] = f.desc
+	}
+
+	return strings.Join(descs, " and ")
+}
+
+func (q *HitQuery) matches(rec HitRecord) bool {
+	for _, f := range q.filters {
+		if !f.match(rec) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,113 @@
+package bogus
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+)
+
+func TestQuery(t *testing.T) {
+	g := goblin.Goblin(t)
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("Querying Hit Records", func() {
+		var server *Bogus
+		var start, middle time.Time
+
+		send := func(method, path, body string, header http.Header) {
+			req, _ := http.NewRequest(method, server.URL()+path, bytes.NewBufferString(body))
+			for k, v := range header {
+				req.Header[k] = v
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			Expect(err).NotTo(HaveOccurred())
+			resp.Body.Close()
+		}
+
+		g.BeforeEach(func() {
+			server = New()
+			server.AddPath("/orders/{id}").
+				SetMethods("GET", "POST")
+
+			start = time.Now()
+			send("GET", "/orders/1", "", nil)
+			send("POST", "/orders/1?notify=true", `{"qty":1}`, http.Header{"X-Rush": {"true"}})
+
+			time.Sleep(time.Millisecond)
+			middle = time.Now()
+			send("POST", "/orders/2", `{"qty":2}`, nil)
+			send("GET", "/elsewhere", "", nil)
+		})
+
+		g.AfterEach(func() {
+			server.Close()
+		})
+
+		g.It("should filter records", func() {
+			Expect(server.Query().Count()).To(Equal(4))
+			Expect(server.Query().Method("post").Count()).To(Equal(2))
+			Expect(server.Query().Path("/orders/1").Count()).To(Equal(2))
+			Expect(server.Query().Route("/orders/{id}").Count()).To(Equal(3))
+			Expect(server.Query().Header("X-Rush", "true").Count()).To(Equal(1))
+			Expect(server.Query().QueryParam("notify", "true").Count()).To(Equal(1))
+			Expect(server.Query().Since(start).Until(middle).Count()).To(Equal(2))
+			Expect(server.Query().Since(middle).Count()).To(Equal(2))
+
+			big := server.Query().Body(func(body []byte) bool {
+				return bytes.Contains(body, []byte(`"qty":2`))
+			})
+			Expect(big.All()).To(HaveLen(1))
+			Expect(big.All()[0].Path).To(Equal("/orders/2"))
+		})
+
+		g.It("should leave the original query unchanged when narrowing", func() {
+			posts := server.Query().Method("POST")
+			rushed := posts.Header("X-Rush", "true")
+
+			Expect(posts.Count()).To(Equal(2))
+			Expect(rushed.Count()).To(Equal(1))
+			Expect(rushed.String()).To(Equal(`method is POST and header X-Rush is "true"`))
+		})
+
+		g.It("should return the first and last records", func() {
+			first, ok := server.Query().Method("POST").First()
+			Expect(ok).To(BeTrue())
+			Expect(first.Path).To(Equal("/orders/1"))
+
+			last, ok := server.Query().Method("POST").Last()
+			Expect(ok).To(BeTrue())
+			Expect(last.Path).To(Equal("/orders/2"))
+
+			_, ok = server.Query().Method("DELETE").First()
+			Expect(ok).To(BeFalse())
+		})
+
+		g.It("should assert a single match", func() {
+			r := &reporter{}
+
+			rec := server.Query().Header("X-Rush", "true").One(r)
+			Expect(r.errors).To(BeEmpty())
+			Expect(rec.Query.Get("notify")).To(Equal("true"))
+
+			server.Query().Method("POST").One(r)
+			Expect(r.errors).To(HaveLen(1))
+			Expect(r.errors[0]).To(HavePrefix("bogus: expected one request where method is POST, got 2"))
+			Expect(r.errors[0]).To(ContainSubstring("POST /orders/2"))
+		})
+
+		g.It("should return a snapshot as hits keep arriving", func() {
+			gets := server.Query().Method("GET")
+			records := gets.All()
+			Expect(records).To(HaveLen(2))
+
+			send("GET", "/orders/3", "", nil)
+			Expect(records).To(HaveLen(2))
+			Expect(gets.Count()).To(Equal(3))
+		})
+	})
+}
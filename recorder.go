@@ -0,0 +1,71 @@
+package bogus
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"net"
+	"net/http"
+)
+
+// responseRecorder passes a response through to the client while keeping a
+// copy of the status, headers, and body written
+type responseRecorder struct {
+	http.ResponseWriter
+
+	status      int
+	header      http.Header
+	body        bytes.Buffer
+	wroteHeader bool
+	hijacked    bool
+}
+
+func newResponseRecorder(w http.ResponseWriter) *responseRecorder {
+	return &responseRecorder{ResponseWriter: w}
+}
+
+func (rr *responseRecorder) WriteHeader(status int) {
+	rr.ResponseWriter.WriteHeader(status)
+
+	if !rr.wroteHeader {
+		rr.wroteHeader = true
+		rr.status = status
+		rr.header = rr.Header().Clone()
+	}
+}
+
+func (rr *responseRecorder) Write(b []byte) (int, error) {
+	if !rr.wroteHeader {
+		rr.WriteHeader(http.StatusOK)
+	}
+
+	rr.body.Write(b)
+	return rr.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher when the underlying writer does
+func (rr *responseRecorder) Flush() {
+	if !rr.wroteHeader {
+		rr.WriteHeader(http.StatusOK)
+	}
+
+	if f, ok := rr.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker when the underlying writer does
+func (rr *responseRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := rr.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("bogus: response writer does not support hijacking")
+	}
+
+	rr.hijacked = true
+	return h.Hijack()
+}
+
+// Unwrap returns the underlying writer for http.ResponseController
+func (rr *responseRecorder) Unwrap() http.ResponseWriter {
+	return rr.ResponseWriter
+}
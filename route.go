@@ -0,0 +1,168 @@
+package bogus
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/gomicro/bogus/paths"
+)
+
+// segment kinds are ordered by their precedence when several routes match the
+// same request path
+const (
+	staticSegment = iota
+	regexpSegment
+	paramSegment
+	wildcardSegment
+)
+
+type segment struct {
+	kind  int
+	value string
+	name  string
+	re    *regexp.Regexp
+}
+
+// route represents a parsed path pattern and the path it routes to. Patterns
+// are made of slash separated segments which may be static text, a named
+// parameter such as {id}, a parameter constrained by a regular expression
+// such as {id:[0-9]+}, or a trailing wildcard such as *rest which captures the
+// remainder of the path. A wildcard needs a segment to capture, so /files/*rest
+// matches /files/ but not /files.
+type route struct {
+	pattern  string
+	segments []segment
+	path     *paths.Path
+}
+
+func parseRoute(pattern string) (*route, error) {
+	parts := strings.Split(pattern, "/")
+	segments := make([]segment, 0, len(parts))
+
+	for i, part := range parts {
+		switch {
+		case strings.HasPrefix(part, "*"):
+			if i != len(parts)-1 {
+				return nil, fmt.Errorf("wildcard segment %q must be last", part)
+			}
+
+			segments = append(segments, segment{
+				kind: wildcardSegment,
+				name: part[1:],
+			})
+
+		case strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}"):
+			name := part[1 : len(part)-1]
+
+			expr := ""
+			if idx := strings.Index(name, ":"); idx >= 0 {
+				name, expr = name[:idx], name[idx+1:]
+			}
+
+			if name == "" {
+				return nil, fmt.Errorf("parameter segment %q is missing a name", part)
+			}
+
+			if expr == "" {
+				segments = append(segments, segment{
+					kind: paramSegment,
+					name: name,
+				})
+				continue
+			}
+
+			re, err := regexp.Compile("^(?:" + expr + ")$")
+			if err != nil {
+				return nil, fmt.Errorf("parameter segment %q: %v", part, err)
+			}
+
+			segments = append(segments, segment{
+				kind: regexpSegment,
+				name: name,
+				re:   re,
+			})
+
+		default:
+			segments = append(segments, segment{
+				kind:  staticSegment,
+				value: part,
+			})
+		}
+	}
+
+	return &route{
+		pattern:  pattern,
+		segments: segments,
+	}, nil
+}
+
+// match reports whether the route matches the request path and returns any
+// parameters captured along the way
+func (rt *route) match(path string) (map[string]string, bool) {
+	parts := strings.Split(path, "/")
+	params := map[string]string{}
+
+	for i, seg := range rt.segments {
+		if seg.kind == wildcardSegment {
+			if i >= len(parts) {
+				return nil, false
+			}
+
+			if seg.name != "" {
+				params[seg.name] = strings.Join(parts[i:], "/")
+			}
+
+			return params, true
+		}
+
+		if i >= len(parts) {
+			return nil, false
+		}
+
+		switch seg.kind {
+		case staticSegment:
+			if parts[i] != seg.value {
+				return nil, false
+			}
+
+		case regexpSegment:
+			if !seg.re.MatchString(parts[i]) {
+				return nil, false
+			}
+
+			params[seg.name] = parts[i]
+
+		case paramSegment:
+			if parts[i] == "" {
+				return nil, false
+			}
+
+			params[seg.name] = parts[i]
+		}
+	}
+
+	if len(parts) != len(rt.segments) {
+		return nil, false
+	}
+
+	return params, true
+}
+
+// before reports whether the route should be tried before another. Segments
+// are compared in order and the first differing kind decides, so static
+// segments win over parameters and parameters win over wildcards. Ties fall
+// back to the pattern itself to keep the ordering deterministic.
+func (rt *route) before(other *route) bool {
+	for i := 0; i < len(rt.segments) && i < len(other.segments); i++ {
+		if rt.segments[i].kind != other.segments[i].kind {
+			return rt.segments[i].kind < other.segments[i].kind
+		}
+	}
+
+	if len(rt.segments) != len(other.segments) {
+		return len(rt.segments) < len(other.segments)
+	}
+
+	return rt.pattern < other.pattern
+}
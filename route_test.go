@@ -0,0 +1,117 @@
+package bogus
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+)
+
+func TestRoute(t *testing.T) {
+	g := goblin.Goblin(t)
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("Routes", func() {
+		g.Describe("Parsing", func() {
+			g.It("should parse static, param, regexp, and wildcard segments", func() {
+				rt, err := parseRoute("/users/{id}/posts/{post:[0-9]+}/*rest")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(rt.segments).To(HaveLen(6))
+
+				Expect(rt.segments[1].kind).To(Equal(staticSegment))
+				Expect(rt.segments[2].kind).To(Equal(paramSegment))
+				Expect(rt.segments[2].name).To(Equal("id"))
+				Expect(rt.segments[4].kind).To(Equal(regexpSegment))
+				Expect(rt.segments[4].name).To(Equal("post"))
+				Expect(rt.segments[5].kind).To(Equal(wildcardSegment))
+				Expect(rt.segments[5].name).To(Equal("rest"))
+			})
+
+			g.It("should reject invalid patterns", func() {
+				_, err := parseRoute("/files/*rest/more")
+				Expect(err).To(HaveOccurred())
+
+				_, err = parseRoute("/users/{}")
+				Expect(err).To(HaveOccurred())
+
+				_, err = parseRoute("/users/{id:[0-9}")
+				Expect(err).To(HaveOccurred())
+			})
+		})
+
+		g.Describe("Matching", func() {
+			g.It("should match static routes exactly", func() {
+				rt, _ := parseRoute("/foo/bar")
+
+				_, ok := rt.match("/foo/bar")
+				Expect(ok).To(BeTrue())
+
+				_, ok = rt.match("/foo/bar/baz")
+				Expect(ok).To(BeFalse())
+
+				_, ok = rt.match("/foo")
+				Expect(ok).To(BeFalse())
+			})
+
+			g.It("should capture params", func() {
+				rt, _ := parseRoute("/users/{id}")
+
+				params, ok := rt.match("/users/42")
+				Expect(ok).To(BeTrue())
+				Expect(params).To(Equal(map[string]string{"id": "42"}))
+
+				_, ok = rt.match("/users/")
+				Expect(ok).To(BeFalse())
+			})
+
+			g.It("should constrain regexp params", func() {
+				rt, _ := parseRoute("/users/{id:[0-9]+}")
+
+				params, ok := rt.match("/users/42")
+				Expect(ok).To(BeTrue())
+				Expect(params["id"]).To(Equal("42"))
+
+				_, ok = rt.match("/users/bob")
+				Expect(ok).To(BeFalse())
+			})
+
+			g.It("should capture the remainder with a wildcard", func() {
+				rt, _ := parseRoute("/files/*rest")
+
+				params, ok := rt.match("/files/a/b/c.txt")
+				Expect(ok).To(BeTrue())
+				Expect(params["rest"]).To(Equal("a/b/c.txt"))
+
+				params, ok = rt.match("/files/")
+				Expect(ok).To(BeTrue())
+				Expect(params["rest"]).To(Equal(""))
+
+				_, ok = rt.match("/files")
+				Expect(ok).To(BeFalse())
+			})
+		})
+
+		g.Describe("Precedence", func() {
+			g.It("should order static before param before wildcard", func() {
+				patterns := []string{"/users/*rest", "/users/{id}", "/users/{id:[0-9]+}", "/users/me"}
+
+				routes := []*route{}
+				for _, p := range patterns {
+					rt, err := parseRoute(p)
+					Expect(err).NotTo(HaveOccurred())
+					routes = append(routes, rt)
+				}
+
+				sort.SliceStable(routes, func(i, j int) bool {
+					return routes[i].before(routes[j])
+				})
+
+				Expect(routes[0].pattern).To(Equal("/users/me"))
+				Expect(routes[1].pattern).To(Equal("/users/{id:[0-9]+}"))
+				Expect(routes[2].pattern).To(Equal("/users/{id}"))
+				Expect(routes[3].pattern).To(Equal("/users/*rest"))
+			})
+		})
+	})
+}
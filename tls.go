@@ -0,0 +1,82 @@
+package bogus
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"net"
+	"time"
+)
+
+// tlsConfig builds the server's TLS config from the options provided. Leaving
+// the certificates empty lets the httptest package supply its own.
+func tlsConfig(cfg *config) (*tls.Config, error) {
+	tlsCfg := &tls.Config{
+		Certificates: cfg.certificates,
+	}
+
+	if cfg.ca != nil {
+		cert, err := issueCertificate(cfg.ca)
+		if err != nil {
+			return nil, err
+		}
+
+		tlsCfg.Certificates = append([]tls.Certificate{cert}, tlsCfg.Certificates...)
+	}
+
+	if cfg.clientCAs != nil {
+		tlsCfg.ClientCAs = cfg.clientCAs
+		tlsCfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return tlsCfg, nil
+}
+
+// issueCertificate creates a short lived certificate for the loopback
+// addresses signed by the certificate authority provided
+func issueCertificate(ca *tls.Certificate) (tls.Certificate, error) {
+	if len(ca.Certificate) == 0 {
+		return tls.Certificate{}, fmt.Errorf("certificate authority is empty")
+	}
+
+	caCert, err := x509.ParseCertificate(ca.Certificate[0])
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("parsing certificate authority: %v", err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("generating key: %v", err)
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("generating serial number: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "bogus"},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, caCert, &key.PublicKey, ca.PrivateKey)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("issuing certificate: %v", err)
+	}
+
+	return tls.Certificate{
+		Certificate: [][]byte{der, ca.Certificate[0]},
+		PrivateKey:  key,
+	}, nil
+}
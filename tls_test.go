@@ -0,0 +1,148 @@
+package bogus
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+)
+
+// newTestCertificate creates a certificate with the common name provided,
+// signed by the parent if one is provided and self signed otherwise
+func newTestCertificate(cn string, isCA bool, parent *tls.Certificate) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).NotTo(HaveOccurred())
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: cn},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  isCA,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
+	}
+
+	signer, signerKey := template, interface{}(key)
+	if parent != nil {
+		signer, err = x509.ParseCertificate(parent.Certificate[0])
+		Expect(err).NotTo(HaveOccurred())
+		signerKey = parent.PrivateKey
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	Expect(err).NotTo(HaveOccurred())
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestTLS(t *testing.T) {
+	g := goblin.Goblin(t)
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("TLS", func() {
+		get := func(server *Bogus, client *http.Client) (*http.Response, error) {
+			server.AddPath("/secure").
+				SetMethods("GET").
+				SetPayload([]byte("secret"))
+
+			return client.Get(server.URL() + "/secure")
+		}
+
+		g.It("should serve over tls with a trusted client", func() {
+			server := New(WithTLS())
+			defer server.Close()
+
+			Expect(server.URL()).To(HavePrefix("https://"))
+
+			host, port := server.HostPort()
+			Expect(host).To(Equal("127.0.0.1"))
+			Expect(port).NotTo(BeEmpty())
+
+			resp, err := get(server, server.Client())
+			Expect(err).NotTo(HaveOccurred())
+			defer resp.Body.Close()
+
+			body, _ := ioutil.ReadAll(resp.Body)
+			Expect(string(body)).To(Equal("secret"))
+
+			_, err = get(server, &http.Client{})
+			Expect(err).To(HaveOccurred())
+		})
+
+		g.It("should serve a provided certificate", func() {
+			cert := newTestCertificate("provided", false, nil)
+			server := New(WithCertificate(cert))
+			defer server.Close()
+
+			resp, err := get(server, server.Client())
+			Expect(err).NotTo(HaveOccurred())
+			defer resp.Body.Close()
+
+			Expect(resp.TLS.PeerCertificates[0].Subject.CommonName).To(Equal("provided"))
+		})
+
+		g.It("should issue a certificate from a provided authority", func() {
+			ca := newTestCertificate("test ca", true, nil)
+			caCert, _ := x509.ParseCertificate(ca.Certificate[0])
+			pool := x509.NewCertPool()
+			pool.AddCert(caCert)
+
+			server := New(WithCA(ca))
+			defer server.Close()
+
+			client := &http.Client{
+				Transport: &http.Transport{
+					TLSClientConfig: &tls.Config{RootCAs: pool},
+				},
+			}
+
+			resp, err := get(server, client)
+			Expect(err).NotTo(HaveOccurred())
+			defer resp.Body.Close()
+
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		})
+
+		g.It("should require client certificates for mutual tls", func() {
+			ca := newTestCertificate("client ca", true, nil)
+			caCert, _ := x509.ParseCertificate(ca.Certificate[0])
+			pool := x509.NewCertPool()
+			pool.AddCert(caCert)
+
+			clientCert := newTestCertificate("some client", false, &ca)
+
+			server := New(WithClientCAs(pool), WithClientCertificate(clientCert))
+			defer server.Close()
+
+			resp, err := get(server, server.Client())
+			Expect(err).NotTo(HaveOccurred())
+			defer resp.Body.Close()
+
+			records := server.HitRecords()
+			Expect(records).To(HaveLen(1))
+			Expect(records[0].PeerCertSubject).To(Equal("CN=some client"))
+
+			plain := New(WithClientCAs(pool))
+			defer plain.Close()
+
+			_, err = get(plain, plain.Client())
+			Expect(err).To(HaveOccurred())
+			Expect(strings.ToLower(err.Error())).To(ContainSubstring("certificate"))
+		})
+	})
+}
@@ -0,0 +1,194 @@
+package bogus
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// SetFailOnUnexpected sets whether requests not routed to any path count as
+// failures when verifying expectations
+func (b *Bogus) SetFailOnUnexpected(fail bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failOnUnexpected = fail
+}
+
+// AssertExpectations reports each path whose hits fall outside of what was
+// declared with Times, AtLeast, AtMost, or Never as an error on the test,
+// along with the closest request the path did not accept. It returns whether
+// all expectations were met.
+func (b *Bogus) AssertExpectations(t testing.TB) bool {
+	t.Helper()
+
+	failures := b.failures()
+	for _, f := range failures {
+		t.Errorf("bogus: %v", f)
+	}
+
+	return len(failures) == 0
+}
+
+// Verify reports unmet expectations as errors on the test. It is shorthand
+// for AssertExpectations when the result is not needed.
+func (b *Bogus) Verify(t testing.TB) {
+	t.Helper()
+	b.AssertExpectations(t)
+}
+
+func (b *Bogus) failures() []string {
+	b.mu.RLock()
+	routes := make([]*route, len(b.routes))
+	copy(routes, b.routes)
+	records := make([]HitRecord, len(b.hitRecords))
+	copy(records, b.hitRecords)
+	failOnUnexpected := b.failOnUnexpected
+	b.mu.RUnlock()
+
+	sort.Slice(routes, func(i, j int) bool {
+		return routes[i].pattern < routes[j].pattern
+	})
+
+	failures := []string{}
+
+	for _, rt := range routes {
+		err := rt.path.Verify()
+		if err == nil {
+			continue
+		}
+
+		msg := fmt.Sprintf("path %v: %v", rt.pattern, err)
+
+		if closest, reasons, ok := closestRecord(rt, records); ok {
+			msg += fmt.Sprintf("\n\tclosest request: %v", indent(closest.String(), "\t\t"))
+			for _, reason := range reasons {
+				msg += "\n\t\tunmet: " + reason
+			}
+		}
+
+		failures = append(failures, msg)
+	}
+
+	if failOnUnexpected {
+		for _, rec := range records {
+			if rec.Route == "" && !rec.Proxied {
+				failures = append(failures, fmt.Sprintf("unexpected request: %v", indent(rec.String(), "\t")))
+			}
+		}
+	}
+
+	return failures
+}
+
+// closestRecord finds the recorded request the route came nearest to
+// accepting, along with the conditions it did not meet
+func closestRecord(rt *route, records []HitRecord) (HitRecord, []string, bool) {
+	var closest HitRecord
+	var closestReasons []string
+	found := false
+
+	for _, rec := range records {
+		reasons := rt.path.Explain(rec.request(), rec.Body)
+
+		if _, ok := rt.match(rec.Path); !ok {
+			reasons = append([]string{"path matches " + rt.pattern}, reasons...)
+		} else if len(reasons) == 0 {
+			continue
+		}
+
+		if !found || len(reasons) < len(closestReasons) {
+			closest = rec
+			closestReasons = reasons
+			found = true
+		}
+	}
+
+	return closest, closestReasons, found
+}
+
+// request rebuilds enough of the original request from the record for it to
+// be matched against again
+func (h HitRecord) request() *http.Request {
+	return &http.Request{
+		Method: h.Verb,
+		URL: &url.URL{
+			Path:     h.Path,
+			RawQuery: h.Query.Encode(),
+		},
+		Header: h.Header,
+	}
+}
+
+// String returns a readable rendering of the record, including the request
+// line followed by any params, headers, and body, then the response once one
+// has been written
+func (h HitRecord) String() string {
+	var sb strings.Builder
+	sb.WriteString(h.Verb + " " + h.Path)
+	if len(h.Query) != 0 {
+		sb.WriteString("?" + h.Query.Encode())
+	}
+
+	if len(h.Params) != 0 {
+		keys := make([]string, 0, len(h.Params))
+		for k := range h.Params {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		sb.WriteString("\nParams:")
+		for _, k := range keys {
+			sb.WriteString(fmt.Sprintf(" %v=%v", k, h.Params[k]))
+		}
+	}
+
+	writeHeader(&sb, h.Header)
+
+	if len(h.Body) != 0 {
+		sb.WriteString(fmt.Sprintf("\n\n%s", h.Body))
+	}
+
+	if h.Status != 0 {
+		sb.WriteString(fmt.Sprintf("\n\n=> %v %v", h.Status, http.StatusText(h.Status)))
+		writeHeader(&sb, h.ResponseHeader)
+
+		if len(h.ResponseBody) != 0 {
+			sb.WriteString(fmt.Sprintf("\n\n%s", h.ResponseBody))
+		}
+	}
+
+	return sb.String()
+}
+
+func writeHeader(sb *strings.Builder, header http.Header) {
+	keys := make([]string, 0, len(header))
+	for k := range header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		sb.WriteString(fmt.Sprintf("\n%v: %v", k, strings.Join(header[k], ", ")))
+	}
+}
+
+func indent(s, prefix string) string {
+	return strings.ReplaceAll(s, "\n", "\n"+prefix)
+}
+
+func (h HitRecord) requestLine() string {
+	line := h.Verb + " " + h.Path
+	if len(h.Query) != 0 {
+		line += "?" + h.Query.Encode()
+	}
+
+	if len(h.Body) != 0 {
+		line += fmt.Sprintf(" body=%q", strings.TrimSpace(string(h.Body)))
+	}
+
+	return line
+}
@@ -0,0 +1,173 @@
+package bogus
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/franela/goblin"
+	"github.com/gomicro/bogus/paths"
+	. "github.com/onsi/gomega"
+)
+
+// reporter captures errors reported against a test without failing it
+type reporter struct {
+	testing.TB
+	errors   []string
+	cleanups []func()
+}
+
+func (r *reporter) Helper() {}
+
+func (r *reporter) Logf(format string, args ...interface{}) {}
+
+func (r *reporter) Cleanup(f func()) {
+	r.cleanups = append(r.cleanups, f)
+}
+
+func (r *reporter) finish() {
+	for i := len(r.cleanups) - 1; i >= 0; i-- {
+		r.cleanups[i]()
+	}
+}
+
+func (r *reporter) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestVerify(t *testing.T) {
+	g := goblin.Goblin(t)
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("Verifying Expectations", func() {
+		var server *Bogus
+		var base string
+
+		g.BeforeEach(func() {
+			server = New()
+			host, port := server.HostPort()
+			base = "http://" + net.JoinHostPort(host, port)
+		})
+
+		g.AfterEach(func() {
+			server.Close()
+		})
+
+		g.It("should pass when hit counts are met", func() {
+			server.AddPath("/ping").
+				SetMethods("GET").
+				Times(1)
+			server.AddPath("/never").
+				SetMethods("GET").
+				Never()
+
+			resp, err := http.Get(base + "/ping")
+			Expect(err).NotTo(HaveOccurred())
+			resp.Body.Close()
+
+			r := &reporter{}
+			Expect(server.AssertExpectations(r)).To(BeTrue())
+			Expect(r.errors).To(BeEmpty())
+		})
+
+		g.It("should report unmet expectations with the closest request", func() {
+			server.AddPath("/users/{id}").
+				SetMethods("POST").
+				RequireHeaders(http.StatusUnauthorized, paths.HeaderPresent("Authorization")).
+				AtLeast(1)
+
+			resp, err := http.Post(base+"/users/42", "text/plain", bytes.NewBufferString("hi"))
+			Expect(err).NotTo(HaveOccurred())
+			resp.Body.Close()
+
+			resp, err = http.Get(base + "/other")
+			Expect(err).NotTo(HaveOccurred())
+			resp.Body.Close()
+
+			r := &reporter{}
+			Expect(server.AssertExpectations(r)).To(BeFalse())
+			Expect(r.errors).To(HaveLen(1))
+			Expect(r.errors[0]).To(ContainSubstring("path /users/{id}: expected at least 1 hits, got 0"))
+			Expect(r.errors[0]).To(ContainSubstring("closest request: POST /users/42"))
+			Expect(r.errors[0]).To(ContainSubstring("\n\t\tContent-Type: text/plain"))
+			Expect(r.errors[0]).To(ContainSubstring("\n\t\thi"))
+			Expect(r.errors[0]).To(ContainSubstring("unmet: header Authorization is present"))
+		})
+
+		g.It("should report paths hit too often", func() {
+			server.AddPath("/once").
+				SetMethods("GET").
+				AtMost(1)
+
+			for i := 0; i < 2; i++ {
+				resp, err := http.Get(base + "/once")
+				Expect(err).NotTo(HaveOccurred())
+				resp.Body.Close()
+			}
+
+			r := &reporter{}
+			server.Verify(r)
+			Expect(r.errors).To(HaveLen(1))
+			Expect(r.errors[0]).To(ContainSubstring("expected at most 1 hits, got 2"))
+		})
+
+		g.It("should optionally report unexpected requests", func() {
+			resp, err := http.Get(base + "/nowhere?x=1")
+			Expect(err).NotTo(HaveOccurred())
+			resp.Body.Close()
+
+			r := &reporter{}
+			Expect(server.AssertExpectations(r)).To(BeTrue())
+
+			server.SetFailOnUnexpected(true)
+
+			Expect(server.AssertExpectations(r)).To(BeFalse())
+			Expect(r.errors).To(HaveLen(1))
+			Expect(r.errors[0]).To(HavePrefix("bogus: unexpected request: GET /nowhere?x=1\n"))
+		})
+
+		g.It("should verify and close servers tied to a test", func() {
+			r := &reporter{}
+			s := NewT(r)
+			s.AddPath("/ping").
+				SetMethods("GET").
+				Times(1)
+
+			host, port := s.HostPort()
+			resp, err := http.Get("http://" + net.JoinHostPort(host, port) + "/pong")
+			Expect(err).NotTo(HaveOccurred())
+			resp.Body.Close()
+
+			Expect(r.cleanups).To(HaveLen(1))
+			r.finish()
+
+			Expect(r.errors).To(HaveLen(2))
+			Expect(r.errors[0]).To(HavePrefix("bogus: path /ping: expected exactly 1 hits, got 0"))
+			Expect(r.errors[1]).To(HavePrefix("bogus: unexpected request: GET /pong"))
+
+			_, err = http.Get("http://" + net.JoinHostPort(host, port) + "/ping")
+			Expect(err).To(HaveOccurred())
+		})
+
+		g.It("should log requests", func() {
+			var mu sync.Mutex
+			logs := []string{}
+			server.SetLogf(func(format string, args ...interface{}) {
+				mu.Lock()
+				defer mu.Unlock()
+				logs = append(logs, fmt.Sprintf(format, args...))
+			})
+
+			resp, err := http.Get(base + "/logged?a=b")
+			Expect(err).NotTo(HaveOccurred())
+			resp.Body.Close()
+
+			mu.Lock()
+			defer mu.Unlock()
+			Expect(logs).To(Equal([]string{"bogus: GET /logged?a=b"}))
+		})
+	})
+}
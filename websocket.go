@@ -0,0 +1,466 @@
+package bogus
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gomicro/bogus/paths"
+)
+
+// websocketGUID is appended to the client's key to build the accept header
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// closeTimeout is how long to wait for the client to answer a close frame
+const closeTimeout = time.Second
+
+// Opcode represents the type of a websocket frame
+type Opcode byte
+
+// Websocket opcodes, as defined by RFC 6455
+const (
+	ContinuationFrame Opcode = 0x0
+	TextFrame         Opcode = 0x1
+	BinaryFrame       Opcode = 0x2
+	CloseFrame        Opcode = 0x8
+	PingFrame         Opcode = 0x9
+	PongFrame         Opcode = 0xA
+)
+
+// Websocket close codes used by the server
+const (
+	CloseNormal          = 1000
+	CloseProtocolError   = 1002
+	ClosePolicyViolation = 1008
+	CloseMessageTooBig   = 1009
+)
+
+// DefaultMaxFrameSize is the largest frame or message a websocket accepts
+// from a client unless told otherwise
+const DefaultMaxFrameSize = 1 << 20
+
+var errMessageTooBig = errors.New("bogus: websocket message is too big")
+
+var opcodeNames = map[Opcode]string{
+	ContinuationFrame: "continuation",
+	TextFrame:         "text",
+	BinaryFrame:       "binary",
+	CloseFrame:        "close",
+	PingFrame:         "ping",
+	PongFrame:         "pong",
+}
+
+// String returns the name of the opcode
+func (o Opcode) String() string {
+	if name, ok := opcodeNames[o]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("opcode %#x", byte(o))
+}
+
+// WebSocketFrame represents a websocket message sent or received on a
+// websocket path. Fragmented messages are recorded once reassembled.
+type WebSocketFrame struct {
+	// Sent is whether the server sent the frame, rather than received it
+	Sent    bool
+	Opcode  Opcode
+	Payload []byte
+}
+
+// CloseCode returns the status code carried by a close frame, or zero if it
+// carries none
+func (f WebSocketFrame) CloseCode() int {
+	if f.Opcode != CloseFrame || len(f.Payload) < 2 {
+		return 0
+	}
+
+	return int(binary.BigEndian.Uint16(f.Payload))
+}
+
+// String returns a readable rendering of the frame
+func (f WebSocketFrame) String() string {
+	dir := "<-"
+	if f.Sent {
+		dir = "->"
+	}
+
+	if f.Opcode == BinaryFrame {
+		return fmt.Sprintf("%v %v %x", dir, f.Opcode, f.Payload)
+	}
+
+	return fmt.Sprintf("%v %v %q", dir, f.Opcode, f.Payload)
+}
+
+// WebSocket represents a websocket endpoint on a bogus server and the
+// conversation it holds with each client that connects
+type WebSocket struct {
+	b    *Bogus
+	path *paths.Path
+
+	mu           sync.RWMutex
+	steps        []websocketStep
+	maxFrameSize int64
+}
+
+type websocketStep struct {
+	send  bool
+	frame WebSocketFrame
+}
+
+type recordKey struct{}
+
+// AddWebSocket adds a websocket endpoint to the bogus server and returns it
+// for scripting the conversation. The path may be a pattern, as with AddPath.
+// Each connection performs the upgrade handshake, then runs through the
+// script in order, and closes normally if the script does not close it
+// itself. Every frame sent and received is recorded on the hit record.
+func (b *Bogus) AddWebSocket(path string) *WebSocket {
+	ws := &WebSocket{b: b, maxFrameSize: DefaultMaxFrameSize}
+	ws.path = b.AddPath(path).
+		SetMethods(http.MethodGet).
+		SetHandlerFunc(ws.serve)
+
+	return ws
+}
+
+// Path returns the underlying path, for declaring expected hits or other
+// conditions on the handshake request
+func (ws *WebSocket) Path() *paths.Path {
+	return ws.path
+}
+
+// Send adds a frame for the server to send to the script and returns the
+// websocket for additional configuration
+func (ws *WebSocket) Send(opcode Opcode, payload []byte) *WebSocket {
+	return ws.add(websocketStep{send: true, frame: WebSocketFrame{Sent: true, Opcode: opcode, Payload: payload}})
+}
+
+// SendText adds a text frame for the server to send to the script and returns
+// the websocket for additional configuration
+func (ws *WebSocket) SendText(text string) *WebSocket {
+	return ws.Send(TextFrame, []byte(text))
+}
+
+// Expect adds a frame the client is expected to send to the script and
+// returns the websocket for additional configuration. When the client sends
+// anything else the server closes the connection with a policy violation.
+// Pings are answered and pongs ignored while waiting.
+func (ws *WebSocket) Expect(opcode Opcode, payload []byte) *WebSocket {
+	return ws.add(websocketStep{frame: WebSocketFrame{Opcode: opcode, Payload: payload}})
+}
+
+// ExpectText adds a text frame the client is expected to send to the script
+// and returns the websocket for additional configuration
+func (ws *WebSocket) ExpectText(text string) *WebSocket {
+	return ws.Expect(TextFrame, []byte(text))
+}
+
+// Close ends the script by closing the connection with the code and reason
+// provided, and returns the websocket
+func (ws *WebSocket) Close(code int, reason string) *WebSocket {
+	return ws.add(websocketStep{send: true, frame: closeFrame(code, reason)})
+}
+
+// SetMaxFrameSize sets the largest frame, or message reassembled from
+// fragments, accepted from a client and returns the websocket for additional
+// configuration. Anything larger closes the connection with
+// CloseMessageTooBig. The default is DefaultMaxFrameSize.
+func (ws *WebSocket) SetMaxFrameSize(size int64) *WebSocket {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	ws.maxFrameSize = size
+	return ws
+}
+
+func (ws *WebSocket) add(step websocketStep) *WebSocket {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	ws.steps = append(ws.steps, step)
+	return ws
+}
+
+// serve performs the handshake and runs the script for a single connection
+func (ws *WebSocket) serve(w http.ResponseWriter, r *http.Request) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if !headerContains(r.Header, "Connection", "upgrade") ||
+		!headerContains(r.Header, "Upgrade", "websocket") ||
+		r.Header.Get("Sec-WebSocket-Version") != "13" || key == "" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket upgrade is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	conn, rw, err := hj.Hijack()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ws.b.done:
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	sum := sha1.Sum([]byte(key + websocketGUID))
+	header := http.Header{}
+	header.Set("Upgrade", "websocket")
+	header.Set("Connection", "Upgrade")
+	header.Set("Sec-WebSocket-Accept", base64.StdEncoding.EncodeToString(sum[:]))
+
+	rw.WriteString("HTTP/1.1 101 Switching Protocols\r\n") //nolint,errcheck
+	header.Write(rw)                                       //nolint,errcheck
+	rw.WriteString("\r\n")                                 //nolint,errcheck
+	if err := rw.Flush(); err != nil {
+		return
+	}
+
+	seq, _ := r.Context().Value(recordKey{}).(int64)
+	ws.b.update(seq, func(h *HitRecord) {
+		h.Status = http.StatusSwitchingProtocols
+		h.ResponseHeader = header
+	})
+
+	c := &websocketConn{ws: ws, seq: seq, conn: conn, rw: rw}
+	c.run()
+}
+
+// websocketConn holds the state of a single websocket conversation
+type websocketConn struct {
+	ws      *WebSocket
+	seq     int64
+	conn    net.Conn
+	rw      *bufio.ReadWriter
+	maxSize int64
+}
+
+// run works through the script, closing normally if it does not close itself
+func (c *websocketConn) run() {
+	c.ws.mu.RLock()
+	steps := make([]websocketStep, len(c.ws.steps))
+	copy(steps, c.ws.steps)
+	c.maxSize = c.ws.maxFrameSize
+	c.ws.mu.RUnlock()
+
+	for _, step := range steps {
+		if step.send {
+			if err := c.write(step.frame); err != nil {
+				return
+			}
+
+			if step.frame.Opcode == CloseFrame {
+				c.awaitClose()
+				return
+			}
+
+			continue
+		}
+
+		f, err := c.read()
+		if err == errMessageTooBig {
+			c.write(closeFrame(CloseMessageTooBig, "message too big")) //nolint,errcheck
+			return
+		}
+
+		if err != nil {
+			return
+		}
+
+		if f.Opcode == CloseFrame {
+			c.write(WebSocketFrame{Sent: true, Opcode: CloseFrame, Payload: f.Payload}) //nolint,errcheck
+			return
+		}
+
+		if f.Opcode != step.frame.Opcode || string(f.Payload) != string(step.frame.Payload) {
+			reason := fmt.Sprintf("expected %v %q", step.frame.Opcode, step.frame.Payload)
+			if c.write(closeFrame(ClosePolicyViolation, reason)) == nil {
+				c.awaitClose()
+			}
+			return
+		}
+	}
+
+	if c.write(closeFrame(CloseNormal, "")) == nil {
+		c.awaitClose()
+	}
+}
+
+// awaitClose waits a short while for the client to answer a close frame
+func (c *websocketConn) awaitClose() {
+	c.conn.SetReadDeadline(time.Now().Add(closeTimeout)) //nolint,errcheck
+
+	for {
+		f, err := c.read()
+		if err != nil || f.Opcode == CloseFrame {
+			return
+		}
+	}
+}
+
+// read reads the next data or close message from the client, reassembling
+// fragments and recording it. Pings are answered and pongs ignored, including
+// those arriving between the fragments of a message. Frames or messages over
+// the size limit are refused with errMessageTooBig.
+func (c *websocketConn) read() (WebSocketFrame, error) {
+	var msg WebSocketFrame
+
+	for {
+		fin, opcode, payload, err := readFrame(c.rw.Reader, c.maxSize-int64(len(msg.Payload)))
+		if err != nil {
+			return msg, err
+		}
+
+		if opcode >= CloseFrame {
+			f := WebSocketFrame{Opcode: opcode, Payload: payload}
+			c.record(f)
+
+			switch opcode {
+			case PingFrame:
+				if err := c.write(WebSocketFrame{Sent: true, Opcode: PongFrame, Payload: payload}); err != nil {
+					return msg, err
+				}
+				continue
+			case PongFrame:
+				continue
+			}
+
+			return f, nil
+		}
+
+		if opcode != ContinuationFrame {
+			msg.Opcode = opcode
+		}
+		msg.Payload = append(msg.Payload, payload...)
+
+		if fin {
+			c.record(msg)
+			return msg, nil
+		}
+	}
+}
+
+// write sends a frame to the client unfragmented and records it
+func (c *websocketConn) write(f WebSocketFrame) error {
+	c.record(f)
+
+	header := []byte{0x80 | byte(f.Opcode)}
+	switch n := len(f.Payload); {
+	case n < 126:
+		header = append(header, byte(n))
+	case n <= 0xFFFF:
+		header = append(header, 126, 0, 0)
+		binary.BigEndian.PutUint16(header[2:], uint16(n))
+	default:
+		header = append(header, 127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(header[2:], uint64(n))
+	}
+
+	c.rw.Write(header)    //nolint,errcheck
+	c.rw.Write(f.Payload) //nolint,errcheck
+	return c.rw.Flush()
+}
+
+func (c *websocketConn) record(f WebSocketFrame) {
+	c.ws.b.update(c.seq, func(h *HitRecord) {
+		h.Frames = append(h.Frames, f)
+	})
+}
+
+// readFrame reads a single frame from the client, which must be masked,
+// refusing payloads longer than the limit before reading them
+func readFrame(r io.Reader, limit int64) (bool, Opcode, []byte, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return false, 0, nil, err
+	}
+
+	fin := head[0]&0x80 != 0
+	opcode := Opcode(head[0] & 0x0F)
+
+	if head[1]&0x80 == 0 {
+		return false, 0, nil, errors.New("bogus: websocket client frame is not masked")
+	}
+
+	length := uint64(head[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+
+	if limit < 0 || length > uint64(limit) {
+		return false, 0, nil, errMessageTooBig
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(r, mask[:]); err != nil {
+		return false, 0, nil, err
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return false, 0, nil, err
+	}
+
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+
+	return fin, opcode, payload, nil
+}
+
+func closeFrame(code int, reason string) WebSocketFrame {
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+
+	return WebSocketFrame{
+		Sent:    true,
+		Opcode:  CloseFrame,
+		Payload: append(payload, reason...),
+	}
+}
+
+// headerContains reports whether any of the comma separated values of the
+// header equal the token provided, ignoring case
+func headerContains(header http.Header, name, token string) bool {
+	for _, v := range header.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,228 @@
+package bogus
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+)
+
+// wsClient is just enough of a websocket client to hold a conversation
+type wsClient struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func dialWebSocket(server *Bogus, path string) (*wsClient, *http.Response) {
+	u, _ := url.Parse(server.URL())
+	conn, err := net.Dial("tcp", u.Host)
+	Expect(err).NotTo(HaveOccurred())
+
+	req, _ := http.NewRequest("GET", server.URL()+path, nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	Expect(req.Write(conn)).To(Succeed())
+
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, req)
+	Expect(err).NotTo(HaveOccurred())
+
+	return &wsClient{conn: conn, r: r}, resp
+}
+
+func (c *wsClient) send(opcode Opcode, payload []byte) {
+	c.sendFragment(true, opcode, payload)
+}
+
+func (c *wsClient) sendFragment(fin bool, opcode Opcode, payload []byte) {
+	first := byte(opcode)
+	if fin {
+		first |= 0x80
+	}
+
+	mask := []byte{1, 2, 3, 4}
+	frame := []byte{first, 0x80 | byte(len(payload))}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+
+	_, err := c.conn.Write(frame)
+	Expect(err).NotTo(HaveOccurred())
+}
+
+func (c *wsClient) receive() (Opcode, []byte) {
+	var head [2]byte
+	_, err := io.ReadFull(c.r, head[:])
+	Expect(err).NotTo(HaveOccurred())
+
+	length := int(head[1] & 0x7F)
+	if length == 126 {
+		var ext [2]byte
+		_, err = io.ReadFull(c.r, ext[:])
+		Expect(err).NotTo(HaveOccurred())
+		length = int(binary.BigEndian.Uint16(ext[:]))
+	}
+
+	payload := make([]byte, length)
+	_, err = io.ReadFull(c.r, payload)
+	Expect(err).NotTo(HaveOccurred())
+
+	return Opcode(head[0] & 0x0F), payload
+}
+
+func TestWebSocket(t *testing.T) {
+	g := goblin.Goblin(t)
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("WebSockets", func() {
+		var server *Bogus
+
+		g.BeforeEach(func() {
+			server = New()
+		})
+
+		g.AfterEach(func() {
+			server.Close()
+		})
+
+		g.It("should run a scripted conversation and record every frame", func() {
+			server.AddWebSocket("/chat/{room}").
+				SendText("welcome").
+				ExpectText("hello").
+				Send(BinaryFrame, []byte{1, 2}).
+				Close(4000, "bye")
+
+			c, resp := dialWebSocket(server, "/chat/lobby")
+			defer c.conn.Close()
+
+			Expect(resp.StatusCode).To(Equal(http.StatusSwitchingProtocols))
+			Expect(resp.Header.Get("Sec-WebSocket-Accept")).To(Equal("s3pPLMBiTxaQ9kYGzzhZRbK+xOo="))
+
+			opcode, payload := c.receive()
+			Expect(opcode).To(Equal(TextFrame))
+			Expect(string(payload)).To(Equal("welcome"))
+
+			c.send(PingFrame, []byte("are you there"))
+			opcode, payload = c.receive()
+			Expect(opcode).To(Equal(PongFrame))
+			Expect(string(payload)).To(Equal("are you there"))
+
+			c.send(TextFrame, []byte("hello"))
+
+			opcode, payload = c.receive()
+			Expect(opcode).To(Equal(BinaryFrame))
+			Expect(payload).To(Equal([]byte{1, 2}))
+
+			opcode, payload = c.receive()
+			Expect(opcode).To(Equal(CloseFrame))
+			Expect(WebSocketFrame{Opcode: opcode, Payload: payload}.CloseCode()).To(Equal(4000))
+			Expect(string(payload[2:])).To(Equal("bye"))
+
+			c.send(CloseFrame, payload[:2])
+
+			Eventually(func() []WebSocketFrame {
+				return server.HitRecords()[0].Frames
+			}).Should(HaveLen(7))
+
+			rec := server.HitRecords()[0]
+			Expect(rec.Status).To(Equal(http.StatusSwitchingProtocols))
+			Expect(rec.Params).To(Equal(map[string]string{"room": "lobby"}))
+			Expect(rec.Frames[0]).To(Equal(WebSocketFrame{Sent: true, Opcode: TextFrame, Payload: []byte("welcome")}))
+			Expect(rec.Frames[1].String()).To(Equal(`<- ping "are you there"`))
+			Expect(rec.Frames[3]).To(Equal(WebSocketFrame{Opcode: TextFrame, Payload: []byte("hello")}))
+			Expect(rec.Frames[6].Sent).To(BeFalse())
+			Expect(rec.Frames[6].CloseCode()).To(Equal(4000))
+		})
+
+		g.It("should close with a policy violation on unexpected frames", func() {
+			server.AddWebSocket("/ws").
+				ExpectText("hello")
+
+			c, _ := dialWebSocket(server, "/ws")
+			defer c.conn.Close()
+
+			c.send(TextFrame, []byte("goodbye"))
+
+			opcode, payload := c.receive()
+			Expect(opcode).To(Equal(CloseFrame))
+			Expect(WebSocketFrame{Opcode: opcode, Payload: payload}.CloseCode()).To(Equal(ClosePolicyViolation))
+			Expect(string(payload[2:])).To(Equal(`expected text "hello"`))
+		})
+
+		g.It("should refuse requests that are not upgrades", func() {
+			server.AddWebSocket("/ws")
+
+			resp, err := http.Get(server.URL() + "/ws")
+			Expect(err).NotTo(HaveOccurred())
+			resp.Body.Close()
+
+			Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+			Expect(server.HitRecords()[0].Status).To(Equal(http.StatusBadRequest))
+		})
+
+		g.It("should close when a client sends too much", func() {
+			server.AddWebSocket("/upload").
+				SetMaxFrameSize(8).
+				ExpectText("ok")
+
+			closeCode := func(c *wsClient) int {
+				opcode, payload := c.receive()
+				Expect(opcode).To(Equal(CloseFrame))
+				return WebSocketFrame{Opcode: opcode, Payload: payload}.CloseCode()
+			}
+
+			c, _ := dialWebSocket(server, "/upload")
+			c.send(TextFrame, []byte("far too long for the limit"))
+			Expect(closeCode(c)).To(Equal(CloseMessageTooBig))
+			c.conn.Close()
+
+			c, _ = dialWebSocket(server, "/upload")
+			c.sendFragment(false, TextFrame, []byte("hello "))
+			c.sendFragment(true, ContinuationFrame, []byte("world"))
+			Expect(closeCode(c)).To(Equal(CloseMessageTooBig))
+			c.conn.Close()
+
+			c, _ = dialWebSocket(server, "/upload")
+			huge := []byte{0x80 | byte(TextFrame), 0x80 | 127, 0, 0, 1, 0, 0, 0, 0, 0, 1, 2, 3, 4}
+			_, err := c.conn.Write(huge)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(closeCode(c)).To(Equal(CloseMessageTooBig))
+			c.conn.Close()
+		})
+
+		g.It("should answer control frames between the fragments of a message", func() {
+			server.AddWebSocket("/ws").
+				ExpectText("hello world").
+				SendText("hi")
+
+			c, _ := dialWebSocket(server, "/ws")
+			defer c.conn.Close()
+
+			c.sendFragment(false, TextFrame, []byte("hello "))
+			c.send(PingFrame, []byte("still here"))
+
+			opcode, payload := c.receive()
+			Expect(opcode).To(Equal(PongFrame))
+			Expect(string(payload)).To(Equal("still here"))
+
+			c.sendFragment(true, ContinuationFrame, []byte("world"))
+
+			opcode, payload = c.receive()
+			Expect(opcode).To(Equal(TextFrame))
+			Expect(string(payload)).To(Equal("hi"))
+
+			opcode, payload = c.receive()
+			Expect(opcode).To(Equal(CloseFrame))
+			Expect(WebSocketFrame{Opcode: opcode, Payload: payload}.CloseCode()).To(Equal(CloseNormal))
+		})
+	})
+}